@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type contextKey int
+
+const (
+	clientIPKey contextKey = iota
+	principalKey
+	authErrorKey
+)
+
+// WithClientIP returns a copy of ctx carrying the client's IP address.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey, ip)
+}
+
+// ClientIP returns the client IP stored in ctx, or "" if there is none.
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey).(string)
+	return ip
+}
+
+// RequestIP returns the IP address a request came from. If trustProxy is set,
+// the last address in X-Forwarded-For, which the proxy appended, is preferred
+// over the peer address. Earlier addresses come from the client and can't be
+// trusted.
+func RequestIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			addresses := strings.Split(values[len(values)-1], ",")
+			if ip := strings.TrimSpace(addresses[len(addresses)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
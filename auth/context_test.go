@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		forwarded  []string
+		trustProxy bool
+		want       string
+	}{
+		{"peer address", nil, false, "192.0.2.1"},
+		{"proxy not trusted", []string{"203.0.113.7"}, false, "192.0.2.1"},
+		{"no header", nil, true, "192.0.2.1"},
+		{"single entry", []string{"203.0.113.7"}, true, "203.0.113.7"},
+		{"spoofed entries", []string{"198.51.100.1, 203.0.113.7"}, true, "203.0.113.7"},
+		{"repeated header", []string{"198.51.100.1", "203.0.113.7"}, true, "203.0.113.7"},
+		{"trailing comma", []string{"198.51.100.1,"}, true, "192.0.2.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/graphql", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			for _, value := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := RequestIP(r, test.trustProxy); got != test.want {
+				t.Errorf("RequestIP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
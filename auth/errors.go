@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+)
+
+// AuthenticationError is returned when credentials are rejected or logins are locked out.
+type AuthenticationError struct {
+	Message string
+	// RetryAfter is how long the caller has to wait before trying again,
+	// or zero if retrying right away is allowed.
+	RetryAfter time.Duration
+}
+
+func (e *AuthenticationError) Error() string {
+	return e.Message
+}
+
+// ErrInvalidCredentials is returned for an unknown email or a wrong password alike.
+var ErrInvalidCredentials = &AuthenticationError{Message: "invalid email or password"}
+
+// PermissionError is returned when the user making a request lacks a permission.
+type PermissionError struct {
+	Permission Permission
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("the %s role is needed to %s", permissionRoles[e.Permission], e.Permission)
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepThreshold is the number of tracked keys above which expired entries
+// are pruned, so that attempts against random emails can't grow the map forever.
+const sweepThreshold = 10000
+
+type attempts struct {
+	count       int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+// Limiter counts failed attempts per key and locks the key out for a while
+// once too many failures happen within the window.
+type Limiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	lockout  time.Duration
+	attempts map[string]*attempts
+	now      func() time.Time
+}
+
+// NewLimiter returns a Limiter that locks a key for lockout after max failures
+// within window.
+func NewLimiter(max int, window, lockout time.Duration) *Limiter {
+	return &Limiter{
+		max:      max,
+		window:   window,
+		lockout:  lockout,
+		attempts: map[string]*attempts{},
+		now:      time.Now,
+	}
+}
+
+// Reserve counts an attempt for key before it is made, so that attempts made
+// at the same time can't together get past the limit. If no attempt is
+// allowed, it reports false and how long to wait. A reserved attempt must be
+// followed by Fail or Release.
+func (l *Limiter) Reserve(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if len(l.attempts) > sweepThreshold {
+		l.sweep(now)
+	}
+	a, ok := l.attempts[key]
+	if !ok || (now.Sub(a.first) > l.window && now.After(a.lockedUntil)) {
+		a = &attempts{first: now}
+		l.attempts[key] = a
+	}
+	if remaining := a.lockedUntil.Sub(now); remaining > 0 {
+		return remaining, false
+	}
+	if a.count >= l.max {
+		// The limit is taken up by attempts still in progress.
+		return l.lockout, false
+	}
+	a.count++
+	return 0, true
+}
+
+// Fail records that a reserved attempt failed, locking key out once max
+// attempts failed within the window.
+func (l *Limiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a, ok := l.attempts[key]
+	if !ok || a.count < l.max {
+		return
+	}
+	now := l.now()
+	a.lockedUntil = now.Add(l.lockout)
+	a.count = 0
+	a.first = now
+}
+
+// Release gives back a reserved attempt that didn't fail.
+func (l *Limiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if a, ok := l.attempts[key]; ok && a.count > 0 {
+		a.count--
+	}
+}
+
+// Reset forgets every failed attempt recorded for key.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, key)
+}
+
+// sweep drops entries whose window and lockout have both passed.
+func (l *Limiter) sweep(now time.Time) {
+	for key, a := range l.attempts {
+		if now.Sub(a.first) > l.window && now.After(a.lockedUntil) {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// LoginThrottle tracks failed logins both per account and per client IP.
+type LoginThrottle struct {
+	Accounts *Limiter
+	IPs      *Limiter
+}
+
+// Begin reserves a login attempt for the account and the IP, returning an
+// AuthenticationError if either is locked out. Accounts are tracked whether
+// they exist or not, so a lockout reveals nothing about which emails are
+// registered. Every successful Begin must be followed by Failed, Succeeded or
+// Release.
+func (t *LoginThrottle) Begin(email, ip string) error {
+	remaining, ok := t.Accounts.Reserve(accountKey(email))
+	if !ok {
+		return lockedError(remaining)
+	}
+	if ip == "" {
+		return nil
+	}
+	if remaining, ok := t.IPs.Reserve(ip); !ok {
+		t.Accounts.Release(accountKey(email))
+		return lockedError(remaining)
+	}
+	return nil
+}
+
+// Failed records that the login attempt failed for the account and the IP.
+func (t *LoginThrottle) Failed(email, ip string) {
+	t.Accounts.Fail(accountKey(email))
+	if ip != "" {
+		t.IPs.Fail(ip)
+	}
+}
+
+// Succeeded clears the failed logins recorded for the account and gives back
+// the IP's attempt.
+func (t *LoginThrottle) Succeeded(email, ip string) {
+	t.Accounts.Reset(accountKey(email))
+	if ip != "" {
+		t.IPs.Release(ip)
+	}
+}
+
+// Release gives back an attempt that ended before the credentials were checked.
+func (t *LoginThrottle) Release(email, ip string) {
+	t.Accounts.Release(accountKey(email))
+	if ip != "" {
+		t.IPs.Release(ip)
+	}
+}
+
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func lockedError(remaining time.Duration) error {
+	return &AuthenticationError{
+		Message:    fmt.Sprintf("too many failed login attempts, try again in %d minute(s)", int(math.Ceil(remaining.Minutes()))),
+		RetryAfter: remaining,
+	}
+}
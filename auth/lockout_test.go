@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestThrottle(max int, now *time.Time) *LoginThrottle {
+	accounts := NewLimiter(max, time.Minute, time.Hour)
+	ips := NewLimiter(max, time.Minute, time.Hour)
+	accounts.now = func() time.Time { return *now }
+	ips.now = func() time.Time { return *now }
+	return &LoginThrottle{Accounts: accounts, IPs: ips}
+}
+
+func TestLoginThrottleLocksAfterMaxFailures(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(3, &now)
+	for i := 0; i < 3; i++ {
+		if err := throttle.Begin("a@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d: unexpected lockout: %v", i+1, err)
+		}
+		throttle.Failed("a@example.com", "10.0.0.1")
+	}
+	err := throttle.Begin("A@example.com", "10.0.0.2")
+	authErr, ok := err.(*AuthenticationError)
+	if !ok {
+		t.Fatalf("expected the account to be locked, got %v", err)
+	}
+	if authErr.RetryAfter != time.Hour {
+		t.Errorf("RetryAfter = %v, want %v", authErr.RetryAfter, time.Hour)
+	}
+	if err := throttle.Begin("b@example.com", "10.0.0.1"); err == nil {
+		t.Error("expected the IP to be locked")
+	}
+
+	now = now.Add(time.Hour + time.Second)
+	if err := throttle.Begin("a@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("expected the lockout to have expired, got %v", err)
+	}
+}
+
+func TestLoginThrottleSuccessClearsFailures(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(3, &now)
+	// Failures per IP aren't cleared by successes, so leave the IP out.
+	for i := 0; i < 10; i++ {
+		if err := throttle.Begin("a@example.com", ""); err != nil {
+			t.Fatalf("attempt %d: unexpected lockout: %v", i+1, err)
+		}
+		if i%2 == 0 {
+			throttle.Failed("a@example.com", "")
+		} else {
+			throttle.Succeeded("a@example.com", "")
+		}
+	}
+}
+
+func TestLoginThrottleConcurrentAttempts(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(5, &now)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+		start   = make(chan struct{})
+		finish  = make(chan struct{})
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if throttle.Begin("a@example.com", "") != nil {
+				return
+			}
+			mu.Lock()
+			allowed++
+			mu.Unlock()
+			// Hold the attempt open until every goroutine has tried.
+			<-finish
+			throttle.Failed("a@example.com", "")
+		}()
+	}
+	close(start)
+	time.Sleep(50 * time.Millisecond)
+	close(finish)
+	wg.Wait()
+
+	if allowed != 5 {
+		t.Errorf("%d attempts were allowed at once, want 5", allowed)
+	}
+	if err := throttle.Begin("a@example.com", ""); err == nil {
+		t.Error("expected the account to be locked after the failed burst")
+	}
+}
+
+func TestLoginThrottleRelease(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(1, &now)
+	if err := throttle.Begin("a@example.com", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	throttle.Release("a@example.com", "10.0.0.1")
+	if err := throttle.Begin("a@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("expected a released attempt to be given back, got %v", err)
+	}
+}
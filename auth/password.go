@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"github.com/leggettc18/hackernews-clone-api/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash is compared against when no user matches, so that a login for an
+// unknown email takes as long as one with a wrong password.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+// VerifyPassword reports whether password matches the user's password hash.
+// user may be nil, in which case the check still costs a full bcrypt comparison.
+func VerifyPassword(user *model.User, password string) bool {
+	if user == nil {
+		model.ComparePasswordHash(dummyHash, []byte(password))
+		return false
+	}
+	return model.ComparePasswordHash(user.HashedPassword, []byte(password))
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Principal is the authenticated user a request is made on behalf of.
+type Principal struct {
+	UserID uint
+	// Roles are the roles granted to the user.
+	Roles []string
+	// TokenID is the ID (jti) of the access token the request was made with.
+	TokenID string
+	// TokenExpiresAt is when that access token expires.
+	TokenExpiresAt time.Time
+}
+
+// HasRole reports whether the principal was granted the role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNotAuthenticated is returned when a request needs a logged in user but
+// was made without an access token.
+var ErrNotAuthenticated = &AuthenticationError{Message: "not logged in"}
+
+// Authenticator validates access tokens.
+type Authenticator interface {
+	// Authenticate returns the principal an access token was issued to, or an
+	// error if the token is invalid, expired or revoked.
+	Authenticate(token string) (*Principal, error)
+}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// FromContext returns the principal stored in ctx, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// RequireUser returns the principal stored in ctx. If there is none it
+// returns why the request's access token was rejected, or
+// ErrNotAuthenticated if it had none.
+func RequireUser(ctx context.Context) (*Principal, error) {
+	if principal, ok := FromContext(ctx); ok {
+		return principal, nil
+	}
+	if err, ok := ctx.Value(authErrorKey).(error); ok {
+		return nil, err
+	}
+	return nil, ErrNotAuthenticated
+}
+
+// Authenticate validates token and returns a copy of ctx carrying the
+// principal it was issued to. A rejected token doesn't fail the request, since
+// anonymous requests are allowed, but is reported by RequireUser. An empty
+// token leaves ctx as it is.
+func Authenticate(ctx context.Context, authenticator Authenticator, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	principal, err := authenticator.Authenticate(token)
+	if err != nil {
+		return context.WithValue(ctx, authErrorKey, err)
+	}
+	return WithPrincipal(ctx, principal)
+}
+
+// BearerToken returns the token from the request's Authorization header, or ""
+// if it has none. Tokens sent without the Bearer scheme are accepted too.
+func BearerToken(r *http.Request) string {
+	fields := strings.Fields(r.Header.Get("Authorization"))
+	switch {
+	case len(fields) == 2 && strings.EqualFold(fields[0], "Bearer"):
+		return fields[1]
+	case len(fields) == 1 && !strings.EqualFold(fields[0], "Bearer"):
+		return fields[0]
+	}
+	return ""
+}
+
+// Middleware authenticates the bearer token of every request once and stores
+// the resulting principal, along with the client's IP address, in the request
+// context for the resolvers.
+func Middleware(authenticator Authenticator, trustProxy bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithClientIP(r.Context(), RequestIP(r, trustProxy))
+		ctx = Authenticate(ctx, authenticator, BearerToken(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
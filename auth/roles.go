@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+// roles lists the roles from least to most privileged.
+var roles = []string{model.RoleUser, model.RoleModerator, model.RoleAdmin}
+
+// ValidRole reports whether role is one of the model's roles.
+func ValidRole(role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ImpliedRoles returns role along with the less privileged roles it includes.
+// Unknown roles include nothing but the user role.
+func ImpliedRoles(role string) []string {
+	for i, r := range roles {
+		if r == role {
+			return append([]string(nil), roles[:i+1]...)
+		}
+	}
+	return []string{model.RoleUser}
+}
+
+// Permission is something only users with certain roles may do. The
+// description reads as the end of "the <role> role is needed to ...".
+type Permission string
+
+// Permissions, and the least privileged role that has each of them.
+const (
+	PermissionModerate    Permission = "moderate other users' posts"
+	PermissionManageUsers Permission = "manage users"
+	PermissionViewEmails  Permission = "view other users' email addresses"
+	PermissionViewStats   Permission = "view server stats"
+)
+
+var permissionRoles = map[Permission]string{
+	PermissionModerate:    model.RoleModerator,
+	PermissionManageUsers: model.RoleAdmin,
+	PermissionViewEmails:  model.RoleAdmin,
+	PermissionViewStats:   model.RoleAdmin,
+}
+
+// Can reports whether the principal has the permission.
+func (p *Principal) Can(permission Permission) bool {
+	role, ok := permissionRoles[permission]
+	return ok && p.HasRole(role)
+}
+
+// Require returns the principal stored in ctx if it has the permission. It
+// fails like RequireUser if there is no principal, and with a PermissionError
+// if the principal lacks the permission.
+func Require(ctx context.Context, permission Permission) (*Principal, error) {
+	principal, err := RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.Can(permission) {
+		return nil, &PermissionError{Permission: permission}
+	}
+	return principal, nil
+}
@@ -0,0 +1,417 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is prepended to the upper-cased flag name to get the
+// environment variable for a setting, e.g. -jwt-secret becomes HN_JWT_SECRET.
+const envPrefix = "HN_"
+
+// Config holds every setting the API server needs.
+//
+// Settings are resolved in the following order, each step overriding the
+// previous one: built-in defaults, the optional YAML config file, environment
+// variables, and finally command line flags.
+type Config struct {
+	Server   Server   `yaml:"server"`
+	Database Database `yaml:"database"`
+	Auth     Auth     `yaml:"auth"`
+	CORS     CORS     `yaml:"cors"`
+	Links    Links    `yaml:"links"`
+	Comments Comments `yaml:"comments"`
+	Votes    Votes    `yaml:"votes"`
+	Ranking  Ranking  `yaml:"ranking"`
+
+	Subscriptions Subscriptions `yaml:"subscriptions"`
+
+	// Args holds the command line arguments remaining after flag parsing.
+	Args []string `yaml:"-"`
+}
+
+// Server configures the HTTP server.
+type Server struct {
+	Addr              string        `yaml:"addr"`
+	SchemaPath        string        `yaml:"schema_path"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	// TrustProxy takes the client IP from the last X-Forwarded-For entry.
+	// Only enable it behind a single reverse proxy that appends to the header.
+	TrustProxy bool `yaml:"trust_proxy"`
+	// StatsPath serves internal counters, such as dropped subscription
+	// events, as JSON to requests with an admin's access token. Empty
+	// disables it.
+	StatsPath string `yaml:"stats_path"`
+}
+
+// Database configures the sqlite database.
+type Database struct {
+	Path string `yaml:"path"`
+	// AutoMigrate applies pending migrations when the database is opened.
+	AutoMigrate bool `yaml:"auto_migrate"`
+	// Seed inserts the sample data on startup.
+	Seed bool `yaml:"seed"`
+}
+
+// Auth configures token signing and login throttling.
+type Auth struct {
+	JWTSecret string `yaml:"jwt_secret"`
+	// Issuer is put in and required of the iss claim of access tokens.
+	Issuer          string        `yaml:"issuer"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	// MaxLoginAttempts is the number of failed logins for one account within
+	// LoginAttemptWindow that locks the account for LockoutDuration.
+	MaxLoginAttempts int `yaml:"max_login_attempts"`
+	// MaxLoginAttemptsPerIP is the same limit applied to a single client IP.
+	MaxLoginAttemptsPerIP int           `yaml:"max_login_attempts_per_ip"`
+	LoginAttemptWindow    time.Duration `yaml:"login_attempt_window"`
+	LockoutDuration       time.Duration `yaml:"lockout_duration"`
+}
+
+// CORS configures cross-origin requests.
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	Debug            bool     `yaml:"debug"`
+}
+
+// Links configures posting links.
+type Links struct {
+	// EditWindow is how long posters can edit or delete their links.
+	// Moderators can do so at any time.
+	EditWindow time.Duration `yaml:"edit_window"`
+	// DuplicateWindow is how long a url can't be submitted again. Resubmitting
+	// it within the window upvotes the existing link instead. Zero allows
+	// duplicates.
+	DuplicateWindow time.Duration `yaml:"duplicate_window"`
+}
+
+// Comments configures discussion threads.
+type Comments struct {
+	// MaxDepth is the deepest a reply can be nested, top-level comments being depth 0.
+	MaxDepth int `yaml:"max_depth"`
+}
+
+// Votes configures voting.
+type Votes struct {
+	// DownvoteKarma is the karma a user needs before they can downvote.
+	DownvoteKarma int `yaml:"downvote_karma"`
+}
+
+// Ranking configures the front page ranking.
+type Ranking struct {
+	// Gravity is how quickly links sink as they age.
+	Gravity float64 `yaml:"gravity"`
+	// RefreshInterval is how often the cached ranks are recomputed.
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	// Window is how long links stay eligible for the ranked feeds.
+	Window time.Duration `yaml:"window"`
+	// PenalizedDomains have their links' rank scaled by DomainPenalty.
+	PenalizedDomains []string `yaml:"penalized_domains"`
+	DomainPenalty    float64  `yaml:"domain_penalty"`
+	// ControversyPenalty scales the rank of links whose comments far outnumber their votes.
+	ControversyPenalty float64 `yaml:"controversy_penalty"`
+}
+
+// Subscriptions configures delivering events to subscribers.
+type Subscriptions struct {
+	// BufferSize is how many events are held for a subscriber that hasn't
+	// received them yet.
+	BufferSize int `yaml:"buffer_size"`
+	// Overflow is what happens when a subscriber's buffer is full:
+	// "drop-oldest" discards the oldest event, "disconnect" ends the
+	// subscription.
+	Overflow string `yaml:"overflow"`
+	// Bus is how events reach subscribers: "memory" within this process, or
+	// "database" through the events table, for several instances sharing a
+	// database.
+	Bus string `yaml:"bus"`
+	// PollInterval is how often the database bus checks for new events.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// Retention is how long events are kept, for subscribers resuming after
+	// a lost connection and for the database bus.
+	Retention time.Duration `yaml:"retention"`
+}
+
+// Default returns the configuration used when nothing else is specified.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:              ":8081",
+			SchemaPath:        "./schema.graphql",
+			ReadHeaderTimeout: 1 * time.Second,
+			WriteTimeout:      10 * time.Second,
+			IdleTimeout:       90 * time.Second,
+			MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		},
+		Database: Database{
+			Path:        "./db.sqlite",
+			AutoMigrate: true,
+		},
+		Auth: Auth{
+			Issuer:                "hackernews-clone-api",
+			AccessTokenTTL:        15 * time.Minute,
+			RefreshTokenTTL:       30 * 24 * time.Hour,
+			MaxLoginAttempts:      5,
+			MaxLoginAttemptsPerIP: 50,
+			LoginAttemptWindow:    15 * time.Minute,
+			LockoutDuration:       15 * time.Minute,
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{"*"},
+		},
+		Links: Links{
+			EditWindow:      2 * time.Hour,
+			DuplicateWindow: 7 * 24 * time.Hour,
+		},
+		Comments: Comments{
+			MaxDepth: 10,
+		},
+		Votes: Votes{
+			DownvoteKarma: 50,
+		},
+		Ranking: Ranking{
+			Gravity:            1.8,
+			RefreshInterval:    time.Minute,
+			Window:             7 * 24 * time.Hour,
+			DomainPenalty:      0.25,
+			ControversyPenalty: 0.5,
+		},
+		Subscriptions: Subscriptions{
+			BufferSize:   64,
+			Overflow:     "drop-oldest",
+			Bus:          "memory",
+			PollInterval: 250 * time.Millisecond,
+			Retention:    time.Hour,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the config file named by
+// -config (or HN_CONFIG), the environment and the given command line arguments.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("hackernews-clone-api", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "path to a YAML config file")
+	cfg.bindFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.Args = fs.Args()
+
+	// Remember the flags given on the command line so they can be re-applied
+	// on top of the file and the environment.
+	explicit := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || f.Name == "config" {
+			return
+		}
+		name := envName(f.Name)
+		if value, ok := os.LookupEnv(name); ok {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %v", value, name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// bindFlags registers a flag for every setting, bound to the fields of cfg.
+func (cfg *Config) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Server.Addr, "addr", cfg.Server.Addr, "address to listen on")
+	fs.StringVar(&cfg.Server.SchemaPath, "schema", cfg.Server.SchemaPath, "path to the GraphQL schema")
+	fs.DurationVar(&cfg.Server.ReadHeaderTimeout, "read-header-timeout", cfg.Server.ReadHeaderTimeout, "time allowed to read request headers")
+	fs.DurationVar(&cfg.Server.WriteTimeout, "write-timeout", cfg.Server.WriteTimeout, "time allowed to write a response")
+	fs.DurationVar(&cfg.Server.IdleTimeout, "idle-timeout", cfg.Server.IdleTimeout, "time to keep idle connections open")
+	fs.IntVar(&cfg.Server.MaxHeaderBytes, "max-header-bytes", cfg.Server.MaxHeaderBytes, "maximum size of request headers")
+	fs.BoolVar(&cfg.Server.TrustProxy, "trust-proxy", cfg.Server.TrustProxy, "take the client IP from X-Forwarded-For")
+	fs.StringVar(&cfg.Server.StatsPath, "stats-path", cfg.Server.StatsPath, "path to serve internal counters to admins on, empty to disable")
+
+	fs.StringVar(&cfg.Database.Path, "db", cfg.Database.Path, "path to the sqlite database")
+	fs.BoolVar(&cfg.Database.AutoMigrate, "auto-migrate", cfg.Database.AutoMigrate, "apply pending migrations on startup")
+	fs.BoolVar(&cfg.Database.Seed, "seed", cfg.Database.Seed, "insert the sample users, links and votes")
+
+	fs.StringVar(&cfg.Auth.JWTSecret, "jwt-secret", cfg.Auth.JWTSecret, "secret used to sign tokens")
+	fs.StringVar(&cfg.Auth.Issuer, "jwt-issuer", cfg.Auth.Issuer, "issuer claim of access tokens")
+	fs.DurationVar(&cfg.Auth.AccessTokenTTL, "access-token-ttl", cfg.Auth.AccessTokenTTL, "lifetime of access tokens")
+	fs.DurationVar(&cfg.Auth.RefreshTokenTTL, "refresh-token-ttl", cfg.Auth.RefreshTokenTTL, "lifetime of refresh tokens")
+	fs.IntVar(&cfg.Auth.MaxLoginAttempts, "max-login-attempts", cfg.Auth.MaxLoginAttempts, "failed logins per account before lockout")
+	fs.IntVar(&cfg.Auth.MaxLoginAttemptsPerIP, "max-login-attempts-per-ip", cfg.Auth.MaxLoginAttemptsPerIP, "failed logins per client IP before lockout")
+	fs.DurationVar(&cfg.Auth.LoginAttemptWindow, "login-attempt-window", cfg.Auth.LoginAttemptWindow, "window in which failed logins are counted")
+	fs.DurationVar(&cfg.Auth.LockoutDuration, "lockout-duration", cfg.Auth.LockoutDuration, "how long a lockout lasts")
+
+	fs.Var((*stringList)(&cfg.CORS.AllowedOrigins), "cors-origins", "comma separated list of allowed origins")
+	fs.BoolVar(&cfg.CORS.AllowCredentials, "cors-credentials", cfg.CORS.AllowCredentials, "allow credentialed cross-origin requests")
+	fs.BoolVar(&cfg.CORS.Debug, "cors-debug", cfg.CORS.Debug, "log CORS decisions")
+
+	fs.DurationVar(&cfg.Links.EditWindow, "link-edit-window", cfg.Links.EditWindow, "how long posters can edit or delete their links")
+	fs.DurationVar(&cfg.Links.DuplicateWindow, "link-duplicate-window", cfg.Links.DuplicateWindow, "how long resubmitting a url upvotes the existing link instead")
+
+	fs.IntVar(&cfg.Comments.MaxDepth, "max-comment-depth", cfg.Comments.MaxDepth, "deepest level a reply can be nested at")
+
+	fs.IntVar(&cfg.Votes.DownvoteKarma, "downvote-karma", cfg.Votes.DownvoteKarma, "karma needed to downvote")
+
+	fs.Float64Var(&cfg.Ranking.Gravity, "ranking-gravity", cfg.Ranking.Gravity, "how quickly links sink as they age")
+	fs.DurationVar(&cfg.Ranking.RefreshInterval, "ranking-refresh-interval", cfg.Ranking.RefreshInterval, "how often link ranks are recomputed")
+	fs.DurationVar(&cfg.Ranking.Window, "ranking-window", cfg.Ranking.Window, "how long links stay in the ranked feeds")
+	fs.Var((*stringList)(&cfg.Ranking.PenalizedDomains), "ranking-penalized-domains", "comma separated list of domains to rank lower")
+	fs.Float64Var(&cfg.Ranking.DomainPenalty, "ranking-domain-penalty", cfg.Ranking.DomainPenalty, "rank factor for penalized domains")
+	fs.Float64Var(&cfg.Ranking.ControversyPenalty, "ranking-controversy-penalty", cfg.Ranking.ControversyPenalty, "rank factor for flamewars")
+
+	fs.IntVar(&cfg.Subscriptions.BufferSize, "subscription-buffer", cfg.Subscriptions.BufferSize, "events buffered per subscriber")
+	fs.StringVar(&cfg.Subscriptions.Overflow, "subscription-overflow", cfg.Subscriptions.Overflow, "what to do when a subscriber's buffer is full: drop-oldest or disconnect")
+	fs.StringVar(&cfg.Subscriptions.Bus, "subscription-bus", cfg.Subscriptions.Bus, "how events reach subscribers: memory or database")
+	fs.DurationVar(&cfg.Subscriptions.PollInterval, "subscription-poll-interval", cfg.Subscriptions.PollInterval, "how often the database bus checks for new events")
+	fs.DurationVar(&cfg.Subscriptions.Retention, "subscription-retention", cfg.Subscriptions.Retention, "how long events are kept for resuming subscriptions")
+}
+
+// loadFile overlays the settings present in the YAML file at path.
+func (cfg *Config) loadFile(path string) error {
+	bstr, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "unable to read config file")
+	}
+	if err := yaml.Unmarshal(bstr, cfg); err != nil {
+		return errors.Wrapf(err, "unable to parse config file %s", path)
+	}
+	return nil
+}
+
+// Validate reports the first setting that can't be used.
+func (cfg *Config) Validate() error {
+	switch {
+	case cfg.Server.Addr == "":
+		return errors.New("config: server address must be set")
+	case !validAddr(cfg.Server.Addr):
+		return fmt.Errorf("config: server address %q must be a host and port, such as :8081", cfg.Server.Addr)
+	case cfg.Server.SchemaPath == "":
+		return errors.New("config: schema path must be set")
+	case cfg.Server.ReadHeaderTimeout <= 0, cfg.Server.WriteTimeout <= 0, cfg.Server.IdleTimeout <= 0:
+		return errors.New("config: server timeouts must be positive")
+	case cfg.Server.MaxHeaderBytes <= 0:
+		return errors.New("config: max header bytes must be positive")
+	case cfg.Database.Path == "":
+		return errors.New("config: database path must be set")
+	case len(cfg.Auth.JWTSecret) < 16:
+		return errors.New("config: jwt secret must be at least 16 characters (set -jwt-secret or HN_JWT_SECRET)")
+	case cfg.Auth.Issuer == "":
+		return errors.New("config: jwt issuer must be set")
+	case cfg.Auth.AccessTokenTTL <= 0, cfg.Auth.RefreshTokenTTL <= 0:
+		return errors.New("config: token lifetimes must be positive")
+	case cfg.Auth.RefreshTokenTTL < cfg.Auth.AccessTokenTTL:
+		return errors.New("config: refresh tokens must outlive access tokens")
+	case cfg.Auth.MaxLoginAttempts <= 0, cfg.Auth.MaxLoginAttemptsPerIP <= 0:
+		return errors.New("config: login attempt limits must be positive")
+	case cfg.Auth.LoginAttemptWindow <= 0, cfg.Auth.LockoutDuration <= 0:
+		return errors.New("config: login attempt window and lockout duration must be positive")
+	case len(cfg.CORS.AllowedOrigins) == 0:
+		return errors.New("config: at least one CORS origin must be allowed")
+	case cfg.Links.EditWindow < 0:
+		return errors.New("config: link edit window can't be negative")
+	case cfg.Links.DuplicateWindow < 0:
+		return errors.New("config: link duplicate window can't be negative")
+	case cfg.Comments.MaxDepth < 0:
+		return errors.New("config: max comment depth can't be negative")
+	case cfg.Ranking.Gravity <= 0:
+		return errors.New("config: ranking gravity must be positive")
+	case cfg.Ranking.RefreshInterval <= 0, cfg.Ranking.Window <= 0:
+		return errors.New("config: ranking refresh interval and window must be positive")
+	case cfg.Ranking.DomainPenalty < 0, cfg.Ranking.DomainPenalty > 1, cfg.Ranking.ControversyPenalty < 0, cfg.Ranking.ControversyPenalty > 1:
+		return errors.New("config: ranking penalties must be between 0 and 1")
+	case cfg.Subscriptions.BufferSize <= 0:
+		return errors.New("config: subscription buffer size must be positive")
+	case cfg.Subscriptions.Overflow != "drop-oldest" && cfg.Subscriptions.Overflow != "disconnect":
+		return errors.New("config: subscription overflow must be drop-oldest or disconnect")
+	case cfg.Subscriptions.Bus != "memory" && cfg.Subscriptions.Bus != "database":
+		return errors.New("config: subscription bus must be memory or database")
+	case cfg.Subscriptions.PollInterval <= 0, cfg.Subscriptions.Retention <= 0:
+		return errors.New("config: subscription poll interval and retention must be positive")
+	case cfg.Server.StatsPath != "" && !strings.HasPrefix(cfg.Server.StatsPath, "/"):
+		return errors.New("config: stats path must start with /")
+	}
+	if cfg.CORS.AllowCredentials {
+		for _, origin := range cfg.CORS.AllowedOrigins {
+			if origin == "*" {
+				return errors.New("config: CORS credentials can't be allowed for the wildcard origin")
+			}
+		}
+	}
+	return nil
+}
+
+// validAddr reports whether addr is a listen address of the form host:port,
+// where the host may be empty and the port is a number.
+func validAddr(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	_, err = strconv.ParseUint(port, 10, 16)
+	return err == nil
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// stringList is a flag.Value holding a comma separated list. Setting it
+// replaces the whole list rather than appending to it.
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*l = list
+	return nil
+}
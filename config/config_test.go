@@ -0,0 +1,216 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123"
+
+// clearEnv unsets the HN_ variables for the test, restoring them afterwards.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, entry := range os.Environ() {
+		if !strings.HasPrefix(entry, envPrefix) {
+			continue
+		}
+		name := entry[:strings.Index(entry, "=")]
+		setenv(t, name, "")
+		os.Unsetenv(name)
+	}
+}
+
+// setenv sets an environment variable for the test.
+func setenv(t *testing.T, name, value string) {
+	t.Helper()
+	old, had := os.LookupEnv(name)
+	os.Setenv(name, value)
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+// writeFile writes a config file for the test and returns its path.
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "hackernews-clone-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+	cfg, err := Load([]string{"-jwt-secret", testSecret, "migrate", "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	if cfg.Server.Addr != want.Server.Addr || cfg.Auth.AccessTokenTTL != want.Auth.AccessTokenTTL || cfg.Ranking.Gravity != want.Ranking.Gravity {
+		t.Errorf("got %+v, want the defaults", cfg)
+	}
+	if strings.Join(cfg.Args, " ") != "migrate up" {
+		t.Errorf("got args %q, want the ones after the flags", cfg.Args)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `
+server:
+  addr: ":1001"
+  write_timeout: 11s
+auth:
+  jwt_secret: "from the file, long enough"
+  access_token_ttl: 5m
+ranking:
+  penalized_domains: [a.example.com, b.example.com]
+`)
+	summary := func(cfg *Config) string {
+		return strings.Join([]string{
+			cfg.Server.Addr,
+			cfg.Server.WriteTimeout.String(),
+			cfg.Server.ReadHeaderTimeout.String(),
+			cfg.Auth.AccessTokenTTL.String(),
+			strings.Join(cfg.Ranking.PenalizedDomains, ","),
+		}, " ")
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{
+			"file over defaults",
+			nil,
+			[]string{"-config", path},
+			":1001 11s 1s 5m0s a.example.com,b.example.com",
+		},
+		{
+			"file named in the environment",
+			map[string]string{"HN_CONFIG": path},
+			nil,
+			":1001 11s 1s 5m0s a.example.com,b.example.com",
+		},
+		{
+			"env over file",
+			map[string]string{"HN_ADDR": ":1002", "HN_ACCESS_TOKEN_TTL": "6m", "HN_RANKING_PENALIZED_DOMAINS": "c.example.com"},
+			[]string{"-config", path},
+			":1002 11s 1s 6m0s c.example.com",
+		},
+		{
+			"flags over env",
+			map[string]string{"HN_ADDR": ":1002", "HN_ACCESS_TOKEN_TTL": "6m"},
+			[]string{"-config", path, "-addr", ":1003", "-read-header-timeout", "3s"},
+			":1003 11s 3s 6m0s a.example.com,b.example.com",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range test.env {
+				setenv(t, name, value)
+			}
+			cfg, err := Load(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summary(cfg); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"unknown flag", "", nil, []string{"-nope"}, "flag provided but not defined"},
+		{"bad duration flag", "", nil, []string{"-write-timeout", "soon"}, "invalid value"},
+		{"bad duration env", "", map[string]string{"HN_WRITE_TIMEOUT": "soon"}, nil, "invalid value \"soon\" for HN_WRITE_TIMEOUT"},
+		{"bad duration in file", "server:\n  write_timeout: soon\n", nil, nil, "unable to parse config file"},
+		{"malformed file", "server: [", nil, nil, "unable to parse config file"},
+		{"missing file", "missing", nil, nil, "unable to read config file"},
+		{"no secret", "", nil, []string{"-jwt-secret", ""}, "jwt secret must be at least 16 characters"},
+		{"invalid after merging", "", map[string]string{"HN_ADDR": "localhost"}, nil, "server address \"localhost\" must be a host and port"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearEnv(t)
+			setenv(t, "HN_JWT_SECRET", testSecret)
+			for name, value := range test.env {
+				setenv(t, name, value)
+			}
+			args := test.args
+			switch test.file {
+			case "":
+			case "missing":
+				args = append([]string{"-config", filepath.Join(os.TempDir(), "missing", "config.yaml")}, args...)
+			default:
+				args = append([]string{"-config", writeFile(t, test.file)}, args...)
+			}
+			_, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+		want   string
+	}{
+		{"valid", func(cfg *Config) {}, ""},
+		{"host and port", func(cfg *Config) { cfg.Server.Addr = "127.0.0.1:8081" }, ""},
+		{"IPv6 address", func(cfg *Config) { cfg.Server.Addr = "[::1]:8081" }, ""},
+		{"empty address", func(cfg *Config) { cfg.Server.Addr = "" }, "server address must be set"},
+		{"address without port", func(cfg *Config) { cfg.Server.Addr = "localhost" }, "must be a host and port"},
+		{"address with bad port", func(cfg *Config) { cfg.Server.Addr = ":99999" }, "must be a host and port"},
+		{"empty secret", func(cfg *Config) { cfg.Auth.JWTSecret = "" }, "jwt secret must be at least 16 characters"},
+		{"short secret", func(cfg *Config) { cfg.Auth.JWTSecret = "short" }, "jwt secret must be at least 16 characters"},
+		{"zero timeout", func(cfg *Config) { cfg.Server.WriteTimeout = 0 }, "server timeouts must be positive"},
+		{"negative token lifetime", func(cfg *Config) { cfg.Auth.AccessTokenTTL = -time.Minute }, "token lifetimes must be positive"},
+		{"refresh shorter than access", func(cfg *Config) { cfg.Auth.RefreshTokenTTL = time.Minute }, "refresh tokens must outlive access tokens"},
+		{"negative edit window", func(cfg *Config) { cfg.Links.EditWindow = -time.Second }, "link edit window can't be negative"},
+		{"penalty above 1", func(cfg *Config) { cfg.Ranking.DomainPenalty = 1.5 }, "ranking penalties must be between 0 and 1"},
+		{"unknown overflow", func(cfg *Config) { cfg.Subscriptions.Overflow = "block" }, "subscription overflow must be"},
+		{"unknown bus", func(cfg *Config) { cfg.Subscriptions.Bus = "redis" }, "subscription bus must be"},
+		{"relative stats path", func(cfg *Config) { cfg.Server.StatsPath = "stats" }, "stats path must start with /"},
+		{"credentials for any origin", func(cfg *Config) { cfg.CORS.AllowCredentials = true }, "CORS credentials can't be allowed for the wildcard origin"},
+		{"no origins", func(cfg *Config) { cfg.CORS.AllowedOrigins = nil }, "at least one CORS origin"},
+	}
+	for _, test := range tests {
+		cfg := Default()
+		cfg.Auth.JWTSecret = testSecret
+		test.change(cfg)
+		err := cfg.Validate()
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		case test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)):
+			t.Errorf("%s: got %v, want an error containing %q", test.name, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package db
+
+import (
+	"github.com/leggettc18/hackernews-clone-api/model"
+	"github.com/pkg/errors"
+)
+
+func (db *DB) GetCommentById(id uint) (*model.Comment, error) {
+	var comment model.Comment
+	return &comment, errors.Wrap(db.First(&comment, id).Error, "unable to get comment")
+}
+
+// GetCommentsByLinkId returns every comment on a link, oldest first, so that a
+// whole thread can be assembled from a single query.
+func (db *DB) GetCommentsByLinkId(linkId uint) ([]*model.Comment, error) {
+	var comments []*model.Comment
+	return comments, errors.Wrap(db.Where("link_id = ?", linkId).Order("created_at, id").Find(&comments).Error, "unable to get comments")
+}
+
+// CountCommentsByLinkId returns the number of comments on a link, replies included.
+func (db *DB) CountCommentsByLinkId(linkId uint) (int, error) {
+	var count int
+	return count, errors.Wrap(db.Model(&model.Comment{}).Where("link_id = ?", linkId).Count(&count).Error, "unable to count comments")
+}
+
+func (db *DB) CreateComment(comment *model.Comment) error {
+	return errors.Wrap(db.Create(comment).Error, "unable to create comment")
+}
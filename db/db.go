@@ -3,126 +3,42 @@ package db
 import (
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
-	"github.com/leggettc18/hackernews-clone-api/model"
-	"golang.org/x/crypto/bcrypt"
-	"time"
-)
-
-var (
-	passwordHash, _ = bcrypt.GenerateFromPassword(
-		[]byte("password"),
-		bcrypt.DefaultCost,
-	)
-	longForm      = "Jan 2, 2006 at 3:04pm (MST)"
-	sampleTime, _ = time.Parse(longForm, "Feb 3, 2013 at 7:54pm (PST)")
-	users         = []model.User{
-		{
-			ID:             0,
-			Name:           "Admin",
-			Email:          "admin@example.com",
-			HashedPassword: passwordHash,
-		},
-	}
-	links = []model.Link{
-		{
-			//ID:          0,
-			CreatedAt:   sampleTime,
-			Url:         "www.howtographql.com",
-			Description: "Fullstack tutorial for Graphql",
-			PosterID:    0,
-		},
-		{
-			//ID:          1,
-			CreatedAt:   sampleTime,
-			Url:         "www.howtographql.com",
-			Description: "Fullstack tutorial for Graphql",
-			PosterID:    0,
-		},
-		{
-			//ID:          2,
-			CreatedAt:   sampleTime,
-			Url:         "www.howtographql.com",
-			Description: "Fullstack tutorial for Graphql",
-			PosterID:    0,
-		},
-		{
-			//ID:          3,
-			CreatedAt:   sampleTime,
-			Url:         "www.howtographql.com",
-			Description: "Fullstack tutorial for Graphql",
-			PosterID:    0,
-		},
-		{
-			//ID:          4,
-			CreatedAt:   sampleTime,
-			Url:         "www.howtographql.com",
-			Description: "Fullstack tutorial for Graphql",
-			PosterID:    0,
-		},
-		{
-			//ID:          5,
-			CreatedAt:   sampleTime,
-			Url:         "www.howtographql.com",
-			Description: "Fullstack tutorial for Graphql",
-			PosterID:    0,
-		},
-		{
-			//ID:          6,
-			CreatedAt:   sampleTime,
-			Url:         "www.howtographql.com",
-			Description: "Fullstack tutorial for Graphql",
-			PosterID:    0,
-		},
-		{
-			//ID:          7,
-			CreatedAt:   sampleTime,
-			Url:         "www.howtographql.com",
-			Description: "Fullstack tutorial for Graphql",
-			PosterID:    0,
-		},
-	}
-	votes = []model.Vote{
-		{
-			ID:     0,
-			UserID: 1,
-			LinkID: 1,
-		},
-	}
+	"github.com/leggettc18/hackernews-clone-api/config"
+	"github.com/pkg/errors"
 )
 
 type DB struct {
 	*gorm.DB
+	config *config.Config
 }
 
-//NewDB returns a new DB connection.
-func NewDB(path string) (*DB, error) {
+// Open returns a new DB connection without touching the schema.
+func Open(cfg *config.Config) (*DB, error) {
 	// connect to the example db, create it if it doesn't exist.
-	db, err := gorm.Open("sqlite3", path)
+	db, err := gorm.Open("sqlite3", cfg.Database.Path)
 	if err != nil {
 		return nil, err
 	}
-	// drop database tables and recreate them fresh
-	db.DropTableIfExists(&model.User{}, &model.Link{}, &model.Vote{})
-	db.AutoMigrate(&model.User{}, &model.Link{}, &model.Vote{})
-
-	// Insert test data
-	for _, user := range users {
-		if err := db.Create(&user).Error; err != nil {
-			return nil, err
-		}
-	}
+	return &DB{db, cfg}, nil
+}
 
-	for _, link := range links {
-		if err := db.Create(&link).Error; err != nil {
-			return nil, err
-		}
+// NewDB returns a new DB connection, applying any pending migrations
+// unless automatic migration is turned off.
+func NewDB(cfg *config.Config) (*DB, error) {
+	db, err := Open(cfg)
+	if err != nil {
+		return nil, err
 	}
-
-	for _, vote := range votes {
-		if err := db.Create(&vote).Error; err != nil {
+	if cfg.Database.AutoMigrate {
+		if _, err := db.MigrateUp(); err != nil {
+			db.Close()
 			return nil, err
 		}
 	}
+	return db, nil
+}
 
-	return &DB{db}, nil
+// IsNotFound reports whether err, or the error it wraps, means no record matched.
+func IsNotFound(err error) bool {
+	return gorm.IsRecordNotFoundError(errors.Cause(err))
 }
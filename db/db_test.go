@@ -0,0 +1,46 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leggettc18/hackernews-clone-api/config"
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+// newTestDB returns a migrated database in a temporary directory, removed
+// when the test ends.
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "hackernews-clone-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(dir, "db.sqlite")
+	cfg.Auth.JWTSecret = "0123456789abcdef0123"
+	database, err := NewDB(cfg)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.Close()
+		os.RemoveAll(dir)
+	})
+	return database
+}
+
+func createTestUser(t *testing.T, database *DB, name string) *model.User {
+	t.Helper()
+	user := &model.User{Email: name + "@example.com", Name: name}
+	if err := database.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
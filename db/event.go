@@ -0,0 +1,49 @@
+package db
+
+import (
+	"time"
+
+	"github.com/leggettc18/hackernews-clone-api/model"
+	"github.com/pkg/errors"
+)
+
+// CreateEvent appends an event to the outbox.
+func (db *DB) CreateEvent(event *model.Event) error {
+	return errors.Wrap(db.Create(event).Error, "unable to create event")
+}
+
+// LatestEventId returns the ID of the most recent event, even if it has been
+// deleted since, or 0 if there never were any.
+func (db *DB) LatestEventId() (uint, error) {
+	var result struct{ ID uint }
+	// AUTOINCREMENT records the largest ID it has handed out in sqlite_sequence.
+	err := db.Raw("SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'events'), 0) AS id").Scan(&result).Error
+	return result.ID, errors.Wrap(err, "unable to get latest event")
+}
+
+// OldestEventId returns the ID of the oldest event still stored, or 0 if there are none.
+func (db *DB) OldestEventId() (uint, error) {
+	var result struct{ ID uint }
+	err := db.Raw("SELECT COALESCE(MIN(id), 0) AS id FROM events").Scan(&result).Error
+	return result.ID, errors.Wrap(err, "unable to get oldest event")
+}
+
+// GetEventsAfter returns up to limit events with IDs greater than id, oldest first.
+func (db *DB) GetEventsAfter(id uint, limit int) ([]*model.Event, error) {
+	var events []*model.Event
+	err := db.Where("id > ?", id).Order("id").Limit(limit).Find(&events).Error
+	return events, errors.Wrap(err, "unable to get events")
+}
+
+// DeleteEventsBefore removes the events created before t.
+func (db *DB) DeleteEventsBefore(t time.Time) error {
+	return errors.Wrap(db.Where("created_at < ?", t).Delete(&model.Event{}).Error, "unable to delete events")
+}
+
+// GetTopicEventsAfter returns up to limit events on topic with IDs greater
+// than id, oldest first.
+func (db *DB) GetTopicEventsAfter(topic string, id uint, limit int) ([]*model.Event, error) {
+	var events []*model.Event
+	err := db.Where("topic = ? AND id > ?", topic, id).Order("id").Limit(limit).Find(&events).Error
+	return events, errors.Wrap(err, "unable to get events")
+}
@@ -1,26 +1,226 @@
 package db
 
 import (
-	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
 	"github.com/leggettc18/hackernews-clone-api/model"
 	"github.com/pkg/errors"
 )
 
+// Link orderings, matching the values of the LinkOrderBy GraphQL enum.
+const (
+	OrderCreatedAtAsc     = "createdAt_ASC"
+	OrderCreatedAtDesc    = "createdAt_DESC"
+	OrderScoreAsc         = "score_ASC"
+	OrderScoreDesc        = "score_DESC"
+	OrderCommentCountAsc  = "commentCount_ASC"
+	OrderCommentCountDesc = "commentCount_DESC"
+
+	// OrderRankDesc orders by the cached front page rank.
+	OrderRankDesc = "rank_DESC"
+	// OrderActivityDesc orders by the time of the latest comment.
+	OrderActivityDesc = "activity_DESC"
+)
+
+const (
+	scoreSQL        = "(SELECT COALESCE(SUM(votes.direction), 0) FROM votes WHERE votes.link_id = links.id)"
+	commentCountSQL = "(SELECT COUNT(*) FROM comments WHERE comments.link_id = links.id)"
+	activitySQL     = "(SELECT MAX(comments.created_at) FROM comments WHERE comments.link_id = links.id)"
+)
+
+var linkOrders = map[string]string{
+	OrderCreatedAtAsc:     "links.created_at ASC, links.id ASC",
+	OrderCreatedAtDesc:    "links.created_at DESC, links.id DESC",
+	OrderScoreAsc:         scoreSQL + " ASC, links.id ASC",
+	OrderScoreDesc:        scoreSQL + " DESC, links.id DESC",
+	OrderCommentCountAsc:  commentCountSQL + " ASC, links.id ASC",
+	OrderCommentCountDesc: commentCountSQL + " DESC, links.id DESC",
+	OrderRankDesc:         "links.rank_score DESC, links.id DESC",
+	OrderActivityDesc:     activitySQL + " DESC, links.id DESC",
+}
+
+// LinkFilter narrows down a list of links. A link matches a term if its
+// description or url contains it.
+type LinkFilter struct {
+	// Or matches links containing at least one of the terms.
+	Or []string
+	// And matches links containing every one of the terms.
+	And []string
+	// PosterID only matches links posted by this user, if not zero.
+	PosterID uint
+	// CreatedSince only matches links created at or after this time, if set.
+	CreatedSince time.Time
+	// ActiveSince only matches links commented on at or after this time, if set.
+	ActiveSince time.Time
+	// Type only matches posts of this type, if set.
+	Type string
+}
+
+// LinkQuery is a filtered, ordered page of links.
+type LinkQuery struct {
+	LinkFilter
+	// OrderBy is one of the Order constants. Links are in insertion order if empty.
+	OrderBy string
+	// First limits the number of links returned. Zero means no limit.
+	First int
+	Skip  int
+}
+
 func (db *DB) GetLinkById(id uint) (*model.Link, error) {
 	var link model.Link
 	return &link, errors.Wrap(db.First(&link, id).Error, "unable to get link")
 }
 
-func (db *DB) SearchLinksByDescription(search string) ([]*model.Link, error) {
+// GetLinkByIdUnscoped returns the link with the given ID even if it has been
+// deleted, like GetLinksByIds does.
+func (db *DB) GetLinkByIdUnscoped(id uint) (*model.Link, error) {
+	var link model.Link
+	return &link, errors.Wrap(db.Unscoped().First(&link, id).Error, "unable to get link")
+}
+
+// GetLinksByIds returns the links with the given IDs in one query, deleted
+// ones included, so that votes and comments can still show what they were on.
+// Links that don't exist are left out, and the order is unspecified.
+func (db *DB) GetLinksByIds(ids []uint) ([]*model.Link, error) {
 	var links []*model.Link
-	return links, errors.Wrap(db.Where("description LIKE ?", fmt.Sprintf("%%%s%%", search)).Find(&links).Error, "unable to get links")
+	return links, errors.Wrap(db.Unscoped().Where("id IN (?)", ids).Find(&links).Error, "unable to get links")
 }
 
-func (db *DB) SearchLinksByUrl(search string) ([]*model.Link, error) {
+// ListLinks returns the links matching the query in a single SQL query.
+func (db *DB) ListLinks(query LinkQuery) ([]*model.Link, error) {
+	scope := db.filterLinks(query.LinkFilter)
+	if query.OrderBy != "" {
+		order, ok := linkOrders[query.OrderBy]
+		if !ok {
+			return nil, errors.Errorf("unknown link order %q", query.OrderBy)
+		}
+		scope = scope.Order(order)
+	} else {
+		scope = scope.Order("links.id")
+	}
+	if query.First > 0 {
+		scope = scope.Limit(query.First)
+	}
+	if query.Skip > 0 {
+		// sqlite only accepts OFFSET together with LIMIT, and gorm drops
+		// negative limits, so use the largest one instead.
+		if query.First <= 0 {
+			scope = scope.Limit(math.MaxInt64)
+		}
+		scope = scope.Offset(query.Skip)
+	}
 	var links []*model.Link
-	return links, errors.Wrap(db.Where("url LIKE ?", fmt.Sprintf("%%%s%%", search)).Find(&links).Error, "unable to get links")
+	return links, errors.Wrap(scope.Find(&links).Error, "unable to get links")
+}
+
+// LinksPage returns a keyset page of the links matching the filter, and whether
+// there are more links past the end of the page.
+func (db *DB) LinksPage(filter LinkFilter, page KeysetPage) ([]*model.Link, bool, error) {
+	scope, limit, reversed := page.apply(db.filterLinks(filter), "links.id")
+	var links []*model.Link
+	if err := scope.Find(&links).Error; err != nil {
+		return nil, false, errors.Wrap(err, "unable to get links")
+	}
+	more := len(links) > limit
+	if more {
+		links = links[:limit]
+	}
+	if reversed {
+		for i, j := 0, len(links)-1; i < j; i, j = i+1, j-1 {
+			links[i], links[j] = links[j], links[i]
+		}
+	}
+	return links, more, nil
+}
+
+// CountLinks returns the number of links matching the filter.
+func (db *DB) CountLinks(filter LinkFilter) (int, error) {
+	var count int
+	return count, errors.Wrap(db.filterLinks(filter).Count(&count).Error, "unable to count links")
+}
+
+func (db *DB) filterLinks(filter LinkFilter) *gorm.DB {
+	scope := db.Model(&model.Link{})
+	if filter.PosterID != 0 {
+		scope = scope.Where("links.poster_id = ?", filter.PosterID)
+	}
+	if !filter.CreatedSince.IsZero() {
+		scope = scope.Where("links.created_at >= ?", filter.CreatedSince)
+	}
+	if filter.Type != "" {
+		scope = scope.Where("links.type = ?", filter.Type)
+	}
+	if !filter.ActiveSince.IsZero() {
+		scope = scope.Where("EXISTS (SELECT 1 FROM comments WHERE comments.link_id = links.id AND comments.created_at >= ?)", filter.ActiveSince)
+	}
+	for _, term := range filter.And {
+		pattern := likePattern(term)
+		scope = scope.Where(`links.description LIKE ? ESCAPE '\' OR links.url LIKE ? ESCAPE '\'`, pattern, pattern)
+	}
+	if len(filter.Or) > 0 {
+		var (
+			clauses []string
+			args    []interface{}
+		)
+		for _, term := range filter.Or {
+			pattern := likePattern(term)
+			clauses = append(clauses, `links.description LIKE ? ESCAPE '\' OR links.url LIKE ? ESCAPE '\'`)
+			args = append(args, pattern, pattern)
+		}
+		scope = scope.Where(strings.Join(clauses, " OR "), args...)
+	}
+	return scope
+}
+
+// likePattern returns a LIKE pattern matching values that contain term,
+// treating any wildcards in term literally.
+func likePattern(term string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(term) + "%"
+}
+
+// FindRecentLinkByUrl returns the earliest link posted to any of urls since
+// the given time, or a not found error if there is none.
+func (db *DB) FindRecentLinkByUrl(urls []string, since time.Time) (*model.Link, error) {
+	var link model.Link
+	err := db.Where("url IN (?) AND created_at >= ?", urls, since).Order("id").First(&link).Error
+	return &link, errors.Wrap(err, "unable to get link")
 }
 
 func (db *DB) CreateLink(link *model.Link) error {
+	if link.Type == "" {
+		link.Type = model.PostTypeLink
+	}
 	return errors.Wrap(db.Create(link).Error, "unable to create link")
 }
+
+// UpdateLink saves the link's description, url and body.
+func (db *DB) UpdateLink(link *model.Link) error {
+	now := time.Now()
+	err := db.Model(link).UpdateColumns(map[string]interface{}{
+		"description": link.Description,
+		"url":         link.Url,
+		"body":        link.Body,
+		"updated_at":  now,
+	}).Error
+	if err != nil {
+		return errors.Wrap(err, "unable to update link")
+	}
+	link.UpdatedAt = &now
+	return nil
+}
+
+// DeleteLink soft-deletes the link. It disappears from every list and lookup
+// but GetLinkByIdUnscoped and GetLinksByIds, and its votes and comments are
+// kept.
+func (db *DB) DeleteLink(link *model.Link) error {
+	now := time.Now()
+	if err := db.Model(link).UpdateColumn("deleted_at", now).Error; err != nil {
+		return errors.Wrap(err, "unable to delete link")
+	}
+	link.DeletedAt = &now
+	return nil
+}
@@ -0,0 +1,33 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package db
+
+import (
+	"testing"
+
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+func TestGetLinkByIdUnscopedFindsDeletedLinks(t *testing.T) {
+	database := newTestDB(t)
+	user := createTestUser(t, database, "alice")
+	link := &model.Link{Description: "Example", Url: "https://example.com/", PosterID: user.ID, Type: model.PostTypeLink}
+	if err := database.CreateLink(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DeleteLink(link); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := database.GetLinkById(link.ID); err == nil {
+		t.Error("GetLinkById returned a deleted link")
+	}
+	found, err := database.GetLinkByIdUnscoped(link.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != link.ID || found.DeletedAt == nil {
+		t.Errorf("got link %d deleted at %v, want deleted link %d", found.ID, found.DeletedAt, link.ID)
+	}
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+// migration is a single numbered schema change. Up applies the change and
+// Down reverts it. Both are plain SQL and may contain several statements, or
+// none if there is nothing to do.
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes whether a known migration has been applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// schemaMigration is a row of the schema_migrations bookkeeping table.
+type schemaMigration struct {
+	Version   int `gorm:"primary_key;auto_increment:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// ensureMigrationsTable creates the schema_migrations table if it doesn't exist yet.
+func (db *DB) ensureMigrationsTable() error {
+	return errors.Wrap(db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`).Error, "unable to create schema_migrations table")
+}
+
+// appliedMigrations returns the applied migration rows keyed by version.
+func (db *DB) appliedMigrations() (map[int]schemaMigration, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	var rows []schemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, errors.Wrap(err, "unable to read schema_migrations")
+	}
+	applied := make(map[int]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// sortedMigrations returns the registered migrations in ascending version order.
+func sortedMigrations() []migration {
+	sorted := make([]migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return sorted
+}
+
+// MigrateUp applies every migration that hasn't been applied yet, in order.
+// It returns the number of migrations applied.
+func (db *DB) MigrateUp() (int, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, m := range sortedMigrations() {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		err := db.runMigration(m, m.Up, func(tx *gorm.DB) error {
+			return tx.Create(&schemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown reverts the most recently applied migrations, newest first.
+// At most steps migrations are reverted. It returns the number reverted.
+func (db *DB) MigrateDown(steps int) (int, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	sorted := sortedMigrations()
+	count := 0
+	for i := len(sorted) - 1; i >= 0 && count < steps; i-- {
+		m := sorted[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		err := db.runMigration(m, m.Down, func(tx *gorm.DB) error {
+			return tx.Delete(&schemaMigration{Version: m.Version}).Error
+		})
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrationStatuses lists every known migration along with when it was applied,
+// if it has been.
+func (db *DB) MigrationStatuses() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var statuses []MigrationStatus
+	for _, m := range sortedMigrations() {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// runMigration executes the given SQL and the bookkeeping step in a single transaction.
+func (db *DB) runMigration(m migration, sql string, record func(tx *gorm.DB) error) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return errors.Wrap(tx.Error, "unable to begin migration")
+	}
+	if strings.TrimSpace(sql) != "" {
+		if err := tx.Exec(sql).Error; err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, fmt.Sprintf("migration %d (%s) failed", m.Version, m.Name))
+		}
+	}
+	if err := record(tx); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, fmt.Sprintf("unable to record migration %d (%s)", m.Version, m.Name))
+	}
+	return errors.Wrap(tx.Commit().Error, fmt.Sprintf("unable to commit migration %d (%s)", m.Version, m.Name))
+}
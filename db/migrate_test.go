@@ -0,0 +1,123 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package db
+
+import (
+	"testing"
+
+	"github.com/leggettc18/hackernews-clone-api/config"
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+// newMemoryDB returns an unmigrated in-memory database.
+func newMemoryDB(t *testing.T) *DB {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Database.Path = ":memory:"
+	cfg.Database.AutoMigrate = false
+	database, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: gets a database of its own.
+	database.DB.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func appliedCount(t *testing.T, database *DB) int {
+	t.Helper()
+	statuses, err := database.MigrationStatuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != len(migrations) {
+		t.Fatalf("got %d statuses, want one per migration (%d)", len(statuses), len(migrations))
+	}
+	applied := 0
+	for i, status := range statuses {
+		if i > 0 && status.Version <= statuses[i-1].Version {
+			t.Fatalf("statuses out of order: %d after %d", status.Version, statuses[i-1].Version)
+		}
+		if status.AppliedAt != nil {
+			if applied != i {
+				t.Fatalf("migration %d applied after a pending one", status.Version)
+			}
+			applied++
+		}
+	}
+	return applied
+}
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	database := newMemoryDB(t)
+	if n := appliedCount(t, database); n != 0 {
+		t.Fatalf("%d migrations applied to a new database", n)
+	}
+	if n, err := database.MigrateUp(); err != nil || n != len(migrations) {
+		t.Fatalf("MigrateUp applied %d of %d: %v", n, len(migrations), err)
+	}
+	if err := database.Seed(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revert one migration at a time, so a broken down step is pinned down,
+	// with data in the tables for the steps that copy it.
+	for applied := len(migrations) - 1; applied >= 0; applied-- {
+		if n, err := database.MigrateDown(1); err != nil || n != 1 {
+			t.Fatalf("reverting to %d migrations: reverted %d: %v", applied, n, err)
+		}
+		if n := appliedCount(t, database); n != applied {
+			t.Fatalf("%d migrations applied, want %d", n, applied)
+		}
+	}
+	if n, err := database.MigrateDown(1); err != nil || n != 0 {
+		t.Errorf("MigrateDown with nothing applied reverted %d: %v", n, err)
+	}
+
+	if n, err := database.MigrateUp(); err != nil || n != len(migrations) {
+		t.Fatalf("MigrateUp after reverting everything applied %d of %d: %v", n, len(migrations), err)
+	}
+	if n, err := database.MigrateUp(); err != nil || n != 0 {
+		t.Errorf("MigrateUp with nothing pending applied %d: %v", n, err)
+	}
+	if err := database.Seed(); err != nil {
+		t.Fatalf("seeding the re-created schema: %v", err)
+	}
+}
+
+func TestMigrationsKeepData(t *testing.T) {
+	database := newMemoryDB(t)
+	if _, err := database.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Seed(); err != nil {
+		t.Fatal(err)
+	}
+	var users, links int
+	database.Model(&model.User{}).Count(&users)
+	database.Model(&model.Link{}).Count(&links)
+
+	// Keep the first migration, which creates the tables.
+	if _, err := database.MigrateDown(len(migrations) - 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+
+	var usersAfter, linksAfter int
+	database.Model(&model.User{}).Count(&usersAfter)
+	database.Model(&model.Link{}).Count(&linksAfter)
+	if usersAfter != users || linksAfter != links {
+		t.Errorf("got %d users and %d links, want %d and %d", usersAfter, linksAfter, users, links)
+	}
+	results, _, err := database.Search("graphql", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Error("search index wasn't rebuilt")
+	}
+}
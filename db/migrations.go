@@ -0,0 +1,461 @@
+package db
+
+// migrations holds every schema change in the order it was introduced.
+// Never edit a migration once it has been released; add a new one instead.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "create_users_links_votes",
+		Up: `
+			CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				email VARCHAR(255),
+				name VARCHAR(255),
+				hashed_password BLOB
+			);
+			CREATE TABLE IF NOT EXISTS links (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME,
+				description VARCHAR(255),
+				url VARCHAR(255),
+				poster_id INTEGER
+			);
+			CREATE TABLE IF NOT EXISTS votes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER,
+				link_id INTEGER
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS votes;
+			DROP TABLE IF EXISTS links;
+			DROP TABLE IF EXISTS users;
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "index_links_poster_and_votes_link",
+		Up: `
+			CREATE INDEX idx_links_poster_id ON links (poster_id);
+			CREATE INDEX idx_votes_link_id ON votes (link_id);
+			CREATE INDEX idx_votes_user_id ON votes (user_id);
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_votes_user_id;
+			DROP INDEX IF EXISTS idx_votes_link_id;
+			DROP INDEX IF EXISTS idx_links_poster_id;
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "create_refresh_and_revoked_tokens",
+		Up: `
+			ALTER TABLE users ADD COLUMN tokens_valid_after DATETIME;
+			CREATE TABLE refresh_tokens (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				token_hash VARCHAR(64) NOT NULL,
+				created_at DATETIME NOT NULL,
+				expires_at DATETIME NOT NULL,
+				revoked_at DATETIME,
+				replaced_by_id INTEGER
+			);
+			CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+			CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+			CREATE TABLE revoked_tokens (
+				jti VARCHAR(64) PRIMARY KEY,
+				expires_at DATETIME NOT NULL
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS revoked_tokens;
+			DROP TABLE IF EXISTS refresh_tokens;
+			CREATE TABLE users_old (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				email VARCHAR(255),
+				name VARCHAR(255),
+				hashed_password BLOB
+			);
+			INSERT INTO users_old (id, email, name, hashed_password)
+				SELECT id, email, name, hashed_password FROM users;
+			DROP TABLE users;
+			ALTER TABLE users_old RENAME TO users;
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "create_comments",
+		Up: `
+			CREATE TABLE comments (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				body TEXT NOT NULL,
+				author_id INTEGER NOT NULL,
+				link_id INTEGER NOT NULL,
+				parent_id INTEGER,
+				depth INTEGER NOT NULL DEFAULT 0
+			);
+			CREATE INDEX idx_comments_link_id ON comments (link_id, created_at);
+			CREATE INDEX idx_comments_parent_id ON comments (parent_id);
+			CREATE INDEX idx_comments_author_id ON comments (author_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS comments;
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "unique_votes_with_direction",
+		Up: `
+			ALTER TABLE votes ADD COLUMN direction INTEGER NOT NULL DEFAULT 1;
+			DELETE FROM votes WHERE id NOT IN (
+				SELECT MIN(id) FROM votes GROUP BY user_id, link_id
+			);
+			CREATE UNIQUE INDEX idx_votes_user_id_link_id ON votes (user_id, link_id);
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_votes_user_id_link_id;
+			CREATE TABLE votes_old (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER,
+				link_id INTEGER
+			);
+			INSERT INTO votes_old (id, user_id, link_id)
+				SELECT id, user_id, link_id FROM votes WHERE direction > 0;
+			DROP TABLE votes;
+			ALTER TABLE votes_old RENAME TO votes;
+			CREATE INDEX idx_votes_link_id ON votes (link_id);
+			CREATE INDEX idx_votes_user_id ON votes (user_id);
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "add_links_rank_score",
+		Up: `
+			ALTER TABLE links ADD COLUMN rank_score REAL NOT NULL DEFAULT 0;
+			ALTER TABLE links ADD COLUMN ranked_at DATETIME;
+			CREATE INDEX idx_links_rank_score ON links (rank_score);
+			CREATE INDEX idx_links_created_at ON links (created_at);
+		`,
+		Down: `
+			CREATE TABLE links_old (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME,
+				description VARCHAR(255),
+				url VARCHAR(255),
+				poster_id INTEGER
+			);
+			INSERT INTO links_old (id, created_at, description, url, poster_id)
+				SELECT id, created_at, description, url, poster_id FROM links;
+			DROP TABLE links;
+			ALTER TABLE links_old RENAME TO links;
+			CREATE INDEX idx_links_poster_id ON links (poster_id);
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "create_full_text_search",
+		Up: `
+			CREATE VIRTUAL TABLE links_fts USING fts5(
+				description, url, content='links', content_rowid='id'
+			);
+			INSERT INTO links_fts (links_fts) VALUES ('rebuild');
+			CREATE TRIGGER links_fts_insert AFTER INSERT ON links BEGIN
+				INSERT INTO links_fts (rowid, description, url) VALUES (new.id, new.description, new.url);
+			END;
+			CREATE TRIGGER links_fts_delete AFTER DELETE ON links BEGIN
+				INSERT INTO links_fts (links_fts, rowid, description, url) VALUES ('delete', old.id, old.description, old.url);
+			END;
+			CREATE TRIGGER links_fts_update AFTER UPDATE OF description, url ON links BEGIN
+				INSERT INTO links_fts (links_fts, rowid, description, url) VALUES ('delete', old.id, old.description, old.url);
+				INSERT INTO links_fts (rowid, description, url) VALUES (new.id, new.description, new.url);
+			END;
+
+			CREATE VIRTUAL TABLE comments_fts USING fts5(
+				body, content='comments', content_rowid='id'
+			);
+			INSERT INTO comments_fts (comments_fts) VALUES ('rebuild');
+			CREATE TRIGGER comments_fts_insert AFTER INSERT ON comments BEGIN
+				INSERT INTO comments_fts (rowid, body) VALUES (new.id, new.body);
+			END;
+			CREATE TRIGGER comments_fts_delete AFTER DELETE ON comments BEGIN
+				INSERT INTO comments_fts (comments_fts, rowid, body) VALUES ('delete', old.id, old.body);
+			END;
+			CREATE TRIGGER comments_fts_update AFTER UPDATE OF body ON comments BEGIN
+				INSERT INTO comments_fts (comments_fts, rowid, body) VALUES ('delete', old.id, old.body);
+				INSERT INTO comments_fts (rowid, body) VALUES (new.id, new.body);
+			END;
+		`,
+		Down: `
+			DROP TRIGGER IF EXISTS comments_fts_update;
+			DROP TRIGGER IF EXISTS comments_fts_delete;
+			DROP TRIGGER IF EXISTS comments_fts_insert;
+			DROP TABLE IF EXISTS comments_fts;
+			DROP TRIGGER IF EXISTS links_fts_update;
+			DROP TRIGGER IF EXISTS links_fts_delete;
+			DROP TRIGGER IF EXISTS links_fts_insert;
+			DROP TABLE IF EXISTS links_fts;
+		`,
+	},
+	{
+		Version: 8,
+		Name:    "add_user_roles",
+		Up: `
+			ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user';
+		`,
+		Down: `
+			CREATE TABLE users_old (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				email VARCHAR(255),
+				name VARCHAR(255),
+				hashed_password BLOB,
+				tokens_valid_after DATETIME
+			);
+			INSERT INTO users_old (id, email, name, hashed_password, tokens_valid_after)
+				SELECT id, email, name, hashed_password, tokens_valid_after FROM users;
+			DROP TABLE users;
+			ALTER TABLE users_old RENAME TO users;
+		`,
+	},
+	{
+		Version: 9,
+		Name:    "add_link_edits_and_deletion",
+		Up: `
+			ALTER TABLE links ADD COLUMN updated_at DATETIME;
+			ALTER TABLE links ADD COLUMN deleted_at DATETIME;
+		`,
+		Down: `
+			DROP TRIGGER IF EXISTS links_fts_update;
+			DROP TRIGGER IF EXISTS links_fts_delete;
+			DROP TRIGGER IF EXISTS links_fts_insert;
+			CREATE TABLE links_old (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME,
+				description VARCHAR(255),
+				url VARCHAR(255),
+				poster_id INTEGER,
+				rank_score REAL NOT NULL DEFAULT 0,
+				ranked_at DATETIME
+			);
+			INSERT INTO links_old (id, created_at, description, url, poster_id, rank_score, ranked_at)
+				SELECT id, created_at, description, url, poster_id, rank_score, ranked_at FROM links;
+			DROP TABLE links;
+			ALTER TABLE links_old RENAME TO links;
+			CREATE INDEX idx_links_poster_id ON links (poster_id);
+			CREATE INDEX idx_links_rank_score ON links (rank_score);
+			CREATE INDEX idx_links_created_at ON links (created_at);
+			CREATE TRIGGER links_fts_insert AFTER INSERT ON links BEGIN
+				INSERT INTO links_fts (rowid, description, url) VALUES (new.id, new.description, new.url);
+			END;
+			CREATE TRIGGER links_fts_delete AFTER DELETE ON links BEGIN
+				INSERT INTO links_fts (links_fts, rowid, description, url) VALUES ('delete', old.id, old.description, old.url);
+			END;
+			CREATE TRIGGER links_fts_update AFTER UPDATE OF description, url ON links BEGIN
+				INSERT INTO links_fts (links_fts, rowid, description, url) VALUES ('delete', old.id, old.description, old.url);
+				INSERT INTO links_fts (rowid, description, url) VALUES (new.id, new.description, new.url);
+			END;
+		`,
+	},
+	{
+		Version: 10,
+		Name:    "add_links_url_index",
+		// Links posted before urls were normalized may lack a scheme; they get
+		// the one normalization assumes so resubmissions match them.
+		Up: `
+			UPDATE links SET url = 'https://' || url
+				WHERE url != '' AND url NOT LIKE 'http://%' AND url NOT LIKE 'https://%';
+			CREATE INDEX idx_links_url ON links (url);
+		`,
+		Down: `
+			DROP INDEX idx_links_url;
+		`,
+	},
+	{
+		Version: 11,
+		Name:    "add_post_types",
+		Up: `
+			ALTER TABLE links ADD COLUMN type VARCHAR(8) NOT NULL DEFAULT 'link';
+			ALTER TABLE links ADD COLUMN body TEXT NOT NULL DEFAULT '';
+			CREATE INDEX idx_links_type ON links (type);
+		`,
+		Down: `
+			DROP TRIGGER IF EXISTS links_fts_update;
+			DROP TRIGGER IF EXISTS links_fts_delete;
+			DROP TRIGGER IF EXISTS links_fts_insert;
+			CREATE TABLE links_old (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME,
+				description VARCHAR(255),
+				url VARCHAR(255),
+				poster_id INTEGER,
+				rank_score REAL NOT NULL DEFAULT 0,
+				ranked_at DATETIME,
+				updated_at DATETIME,
+				deleted_at DATETIME
+			);
+			INSERT INTO links_old (id, created_at, description, url, poster_id, rank_score, ranked_at, updated_at, deleted_at)
+				SELECT id, created_at, description, url, poster_id, rank_score, ranked_at, updated_at, deleted_at FROM links;
+			DROP TABLE links;
+			ALTER TABLE links_old RENAME TO links;
+			CREATE INDEX idx_links_poster_id ON links (poster_id);
+			CREATE INDEX idx_links_rank_score ON links (rank_score);
+			CREATE INDEX idx_links_created_at ON links (created_at);
+			CREATE INDEX idx_links_url ON links (url);
+			CREATE TRIGGER links_fts_insert AFTER INSERT ON links BEGIN
+				INSERT INTO links_fts (rowid, description, url) VALUES (new.id, new.description, new.url);
+			END;
+			CREATE TRIGGER links_fts_delete AFTER DELETE ON links BEGIN
+				INSERT INTO links_fts (links_fts, rowid, description, url) VALUES ('delete', old.id, old.description, old.url);
+			END;
+			CREATE TRIGGER links_fts_update AFTER UPDATE OF description, url ON links BEGIN
+				INSERT INTO links_fts (links_fts, rowid, description, url) VALUES ('delete', old.id, old.description, old.url);
+				INSERT INTO links_fts (rowid, description, url) VALUES (new.id, new.description, new.url);
+			END;
+		`,
+	},
+	{
+		Version: 12,
+		Name:    "add_unique_user_emails_and_names",
+		// Duplicates from before the indexes existed are renamed, keeping the
+		// oldest account as it was, since the indexes can't be created otherwise.
+		Up: `
+			UPDATE users SET email = email || '.duplicate-' || id
+				WHERE id NOT IN (SELECT MIN(id) FROM users GROUP BY email COLLATE NOCASE);
+			UPDATE users SET name = name || '-' || id
+				WHERE id NOT IN (SELECT MIN(id) FROM users GROUP BY name COLLATE NOCASE);
+			CREATE UNIQUE INDEX idx_users_email ON users (email COLLATE NOCASE);
+			CREATE UNIQUE INDEX idx_users_name ON users (name COLLATE NOCASE);
+		`,
+		Down: `
+			DROP INDEX idx_users_name;
+			DROP INDEX idx_users_email;
+		`,
+	},
+	{
+		Version: 13,
+		Name:    "create_events",
+		// AUTOINCREMENT keeps IDs from being reused once old events are
+		// deleted, so pollers can't mistake a new event for one they've seen.
+		Up: `
+			CREATE TABLE events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				topic VARCHAR(32) NOT NULL,
+				payload TEXT NOT NULL,
+				created_at DATETIME NOT NULL
+			);
+			CREATE INDEX idx_events_created_at ON events (created_at);
+		`,
+		Down: `
+			DROP TABLE events;
+		`,
+	},
+	{
+		Version: 14,
+		Name:    "add_events_topic_index",
+		Up: `
+			CREATE INDEX idx_events_topic_id ON events (topic, id);
+		`,
+		Down: `
+			DROP INDEX idx_events_topic_id;
+		`,
+	},
+	{
+		Version: 15,
+		Name:    "replace_tokens_valid_after_with_token_generation",
+		// tokens_valid_after only had the second precision of the iat claim,
+		// so tokens issued in the same second as a revocation were rejected.
+		// Users who revoked their sessions start at generation 1, which
+		// invalidates the tokens issued before.
+		Up: `
+			CREATE TABLE users_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				email VARCHAR(255),
+				name VARCHAR(255),
+				hashed_password BLOB,
+				role VARCHAR(16) NOT NULL DEFAULT 'user',
+				token_generation INTEGER NOT NULL DEFAULT 0
+			);
+			INSERT INTO users_new (id, email, name, hashed_password, role, token_generation)
+				SELECT id, email, name, hashed_password, role,
+					CASE WHEN tokens_valid_after IS NULL THEN 0 ELSE 1 END
+				FROM users;
+			DROP TABLE users;
+			ALTER TABLE users_new RENAME TO users;
+			CREATE UNIQUE INDEX idx_users_email ON users (email COLLATE NOCASE);
+			CREATE UNIQUE INDEX idx_users_name ON users (name COLLATE NOCASE);
+		`,
+		Down: `
+			CREATE TABLE users_old (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				email VARCHAR(255),
+				name VARCHAR(255),
+				hashed_password BLOB,
+				tokens_valid_after DATETIME,
+				role VARCHAR(16) NOT NULL DEFAULT 'user'
+			);
+			INSERT INTO users_old (id, email, name, hashed_password, tokens_valid_after, role)
+				SELECT id, email, name, hashed_password,
+					CASE WHEN token_generation > 0 THEN CURRENT_TIMESTAMP END, role
+				FROM users;
+			DROP TABLE users;
+			ALTER TABLE users_old RENAME TO users;
+			CREATE UNIQUE INDEX idx_users_email ON users (email COLLATE NOCASE);
+			CREATE UNIQUE INDEX idx_users_name ON users (name COLLATE NOCASE);
+		`,
+	},
+	{
+		Version: 16,
+		Name:    "backfill_link_types",
+		// The seed data used to insert links without a type. There is nothing
+		// to revert: the column has always defaulted to 'link'.
+		Up: `
+			UPDATE links SET type = 'link' WHERE type = '';
+		`,
+		Down: "",
+	},
+	{
+		Version: 17,
+		Name:    "add_link_bodies_to_full_text_search",
+		Up: `
+			DROP TRIGGER links_fts_update;
+			DROP TRIGGER links_fts_delete;
+			DROP TRIGGER links_fts_insert;
+			DROP TABLE links_fts;
+			CREATE VIRTUAL TABLE links_fts USING fts5(
+				description, url, body, content='links', content_rowid='id'
+			);
+			INSERT INTO links_fts (links_fts) VALUES ('rebuild');
+			CREATE TRIGGER links_fts_insert AFTER INSERT ON links BEGIN
+				INSERT INTO links_fts (rowid, description, url, body) VALUES (new.id, new.description, new.url, new.body);
+			END;
+			CREATE TRIGGER links_fts_delete AFTER DELETE ON links BEGIN
+				INSERT INTO links_fts (links_fts, rowid, description, url, body) VALUES ('delete', old.id, old.description, old.url, old.body);
+			END;
+			CREATE TRIGGER links_fts_update AFTER UPDATE OF description, url, body ON links BEGIN
+				INSERT INTO links_fts (links_fts, rowid, description, url, body) VALUES ('delete', old.id, old.description, old.url, old.body);
+				INSERT INTO links_fts (rowid, description, url, body) VALUES (new.id, new.description, new.url, new.body);
+			END;
+		`,
+		Down: `
+			DROP TRIGGER links_fts_update;
+			DROP TRIGGER links_fts_delete;
+			DROP TRIGGER links_fts_insert;
+			DROP TABLE links_fts;
+			CREATE VIRTUAL TABLE links_fts USING fts5(
+				description, url, content='links', content_rowid='id'
+			);
+			INSERT INTO links_fts (links_fts) VALUES ('rebuild');
+			CREATE TRIGGER links_fts_insert AFTER INSERT ON links BEGIN
+				INSERT INTO links_fts (rowid, description, url) VALUES (new.id, new.description, new.url);
+			END;
+			CREATE TRIGGER links_fts_delete AFTER DELETE ON links BEGIN
+				INSERT INTO links_fts (links_fts, rowid, description, url) VALUES ('delete', old.id, old.description, old.url);
+			END;
+			CREATE TRIGGER links_fts_update AFTER UPDATE OF description, url ON links BEGIN
+				INSERT INTO links_fts (links_fts, rowid, description, url) VALUES ('delete', old.id, old.description, old.url);
+				INSERT INTO links_fts (rowid, description, url) VALUES (new.id, new.description, new.url);
+			END;
+		`,
+	},
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+// KeysetPage selects a page of rows by their ID rather than by offset, so rows
+// inserted while a client pages through a list don't shift the pages.
+//
+// After and Before are exclusive bounds, zero meaning unbounded. Either First
+// takes rows from the start of the bounded range or Last takes them from its end.
+type KeysetPage struct {
+	After  uint
+	Before uint
+	First  int
+	Last   int
+	// Descending orders rows by ID from newest to oldest.
+	Descending bool
+}
+
+// apply restricts scope to the page, ordering by the given ID column. One row
+// more than requested is selected to tell whether there are more. If the rows
+// come back in reverse order, reversed is true.
+func (p KeysetPage) apply(scope *gorm.DB, column string) (limited *gorm.DB, limit int, reversed bool) {
+	lower, upper := p.After, p.Before
+	if p.Descending {
+		lower, upper = p.Before, p.After
+	}
+	if lower != 0 {
+		scope = scope.Where(fmt.Sprintf("%s > ?", column), lower)
+	}
+	if upper != 0 {
+		scope = scope.Where(fmt.Sprintf("%s < ?", column), upper)
+	}
+
+	limit = p.First
+	reversed = false
+	if p.Last > 0 {
+		limit = p.Last
+		reversed = true
+	}
+	descending := p.Descending != reversed
+	if descending {
+		scope = scope.Order(column + " DESC")
+	} else {
+		scope = scope.Order(column + " ASC")
+	}
+	return scope.Limit(limit + 1), limit, reversed
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leggettc18/hackernews-clone-api/model"
+	"github.com/pkg/errors"
+)
+
+// RankingInput is what the ranking of a link is computed from.
+type RankingInput struct {
+	ID           uint
+	CreatedAt    time.Time
+	Url          string
+	Score        int
+	CommentCount int
+}
+
+const rankingInputSQL = `SELECT links.id, links.created_at, links.url,
+	` + scoreSQL + ` AS score,
+	` + commentCountSQL + ` AS comment_count
+	FROM links`
+
+// GetRankingInputs returns the ranking inputs of every link created since the given time.
+func (db *DB) GetRankingInputs(since time.Time) ([]RankingInput, error) {
+	var inputs []RankingInput
+	err := db.Raw(rankingInputSQL+" WHERE links.created_at >= ? AND links.deleted_at IS NULL", since).Scan(&inputs).Error
+	return inputs, errors.Wrap(err, "unable to get ranking inputs")
+}
+
+// GetRankingInput returns the ranking input of a single link.
+func (db *DB) GetRankingInput(linkId uint) (*RankingInput, error) {
+	var input RankingInput
+	err := db.Raw(rankingInputSQL+" WHERE links.id = ?", linkId).Scan(&input).Error
+	return &input, errors.Wrap(err, "unable to get ranking input")
+}
+
+// GetLinkScore returns the sum of the votes on a link.
+func (db *DB) GetLinkScore(linkId uint) (int, error) {
+	var result struct {
+		Score int
+	}
+	err := db.Raw("SELECT COALESCE(SUM(direction), 0) AS score FROM votes WHERE link_id = ?", linkId).Scan(&result).Error
+	return result.Score, errors.Wrap(err, "unable to get link score")
+}
+
+// UpdateLinkRanks stores the given rank scores, keyed by link ID, in one transaction.
+func (db *DB) UpdateLinkRanks(ranks map[uint]float64) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		for id, rank := range ranks {
+			err := tx.Model(&model.Link{ID: id}).
+				UpdateColumns(map[string]interface{}{"rank_score": rank, "ranked_at": now}).Error
+			if err != nil {
+				return errors.Wrap(err, "unable to update link rank")
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leggettc18/hackernews-clone-api/auth"
+	"github.com/leggettc18/hackernews-clone-api/model"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidRefreshToken is returned for refresh tokens that are unknown, expired or revoked.
+var ErrInvalidRefreshToken = &auth.AuthenticationError{Message: "invalid refresh token"}
+
+// CreateRefreshToken issues a new refresh token for the user and returns the
+// raw token. Only its hash is stored, so the raw value can't be recovered later.
+func (db *DB) CreateRefreshToken(userID uint) (string, error) {
+	raw, _, err := createRefreshToken(db.DB, userID, db.config.Auth.RefreshTokenTTL)
+	return raw, err
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one. The old token is
+// revoked. Presenting an already revoked token is treated as theft, and every
+// session of its user is revoked.
+func (db *DB) RotateRefreshToken(raw string) (*model.User, string, error) {
+	var (
+		token  model.RefreshToken
+		newRaw string
+	)
+	err := db.Where("token_hash = ?", hashRefreshToken(raw)).First(&token).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, "", ErrInvalidRefreshToken
+		}
+		return nil, "", errors.Wrap(err, "unable to get refresh token")
+	}
+	if token.RevokedAt != nil {
+		if err := db.RevokeAllSessions(token.UserID); err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var (
+			replacement *model.RefreshToken
+			err         error
+		)
+		newRaw, replacement, err = createRefreshToken(tx, token.UserID, db.config.Auth.RefreshTokenTTL)
+		if err != nil {
+			return err
+		}
+		// Only revoke the token if nobody else rotated it in the meantime.
+		result := tx.Model(&model.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", token.ID).
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "replaced_by_id": replacement.ID})
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "unable to revoke refresh token")
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidRefreshToken
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	user, err := db.GetUserById(token.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, newRaw, nil
+}
+
+// RevokeRefreshToken revokes a single refresh token belonging to the user.
+func (db *DB) RevokeRefreshToken(userID uint, raw string) error {
+	return errors.Wrap(db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND token_hash = ? AND revoked_at IS NULL", userID, hashRefreshToken(raw)).
+		Update("revoked_at", time.Now()).Error, "unable to revoke refresh token")
+}
+
+// RevokeAllSessions revokes every refresh token of the user and invalidates all
+// access tokens issued to them so far.
+func (db *DB) RevokeAllSessions(userID uint) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return errors.Wrap(err, "unable to revoke refresh tokens")
+		}
+		err = tx.Model(&model.User{ID: userID}).Update("token_generation", gorm.Expr("token_generation + 1")).Error
+		return errors.Wrap(err, "unable to revoke access tokens")
+	})
+}
+
+func createRefreshToken(tx *gorm.DB, userID uint, ttl time.Duration) (string, *model.RefreshToken, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	token := model.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashRefreshToken(raw),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := tx.Create(&token).Error; err != nil {
+		return "", nil, errors.Wrap(err, "unable to create refresh token")
+	}
+	return raw, &token, nil
+}
+
+func hashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
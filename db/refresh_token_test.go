@@ -0,0 +1,81 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	database := newTestDB(t)
+	user := createTestUser(t, database, "alice")
+	first, err := database.CreateRefreshToken(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotatedFor, second, err := database.RotateRefreshToken(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotatedFor.ID != user.ID {
+		t.Errorf("rotated for user %d, want %d", rotatedFor.ID, user.ID)
+	}
+	if second == first {
+		t.Error("rotation returned the same token")
+	}
+	if _, third, err := database.RotateRefreshToken(second); err != nil || third == "" {
+		t.Errorf("rotating the new token: %v", err)
+	}
+}
+
+func TestReusedRefreshTokenRevokesAllSessions(t *testing.T) {
+	database := newTestDB(t)
+	user := createTestUser(t, database, "alice")
+	stolen, err := database.CreateRefreshToken(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, current, err := database.RotateRefreshToken(stolen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, err := database.GenerateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := database.RotateRefreshToken(stolen); err != ErrInvalidRefreshToken {
+		t.Fatalf("reusing a rotated token: got %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, _, err := database.RotateRefreshToken(current); err != ErrInvalidRefreshToken {
+		t.Errorf("token of the same family after reuse: got %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := database.Authenticate(accessToken); err != ErrTokenRevoked {
+		t.Errorf("access token after reuse: got %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestExpiredAndUnknownRefreshTokens(t *testing.T) {
+	database := newTestDB(t)
+	user := createTestUser(t, database, "alice")
+	raw, err := database.CreateRefreshToken(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = database.Model(&model.RefreshToken{}).Where("user_id = ?", user.ID).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := database.RotateRefreshToken(raw); err != ErrInvalidRefreshToken {
+		t.Errorf("expired token: got %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, _, err := database.RotateRefreshToken("not-a-token"); err != ErrInvalidRefreshToken {
+		t.Errorf("unknown token: got %v, want ErrInvalidRefreshToken", err)
+	}
+}
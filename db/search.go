@@ -0,0 +1,242 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of search results.
+const (
+	SearchKindLink    = "link"
+	SearchKindComment = "comment"
+)
+
+// Markers placed around the matched terms in search snippets. They are
+// control characters so they can't clash with the indexed text.
+const (
+	HighlightStart = "\x02"
+	HighlightEnd   = "\x03"
+)
+
+// ErrNoSearchTerms is returned for search queries without any words.
+var ErrNoSearchTerms = errors.New("search query has no terms")
+
+// snippetTokens is the number of tokens shown around the match in a snippet.
+const snippetTokens = 16
+
+// SearchResult is one link or comment matching a search.
+type SearchResult struct {
+	Kind string
+	// ID is the ID of the link or comment.
+	ID uint
+	// LinkID is the link the result belongs to; equal to ID for links.
+	LinkID uint
+	// Rank is the bm25 relevance; lower is more relevant.
+	Rank float64
+	// Snippet is an excerpt of the matching text with the matched terms
+	// wrapped in HighlightStart and HighlightEnd.
+	Snippet string
+}
+
+// searchTable describes one full-text index that can be searched.
+type searchTable struct {
+	kind    string
+	columns map[string]bool
+	sql     string
+}
+
+var searchTables = []searchTable{
+	{
+		kind:    SearchKindLink,
+		columns: map[string]bool{"description": true, "url": true, "body": true},
+		sql: `SELECT 'link' AS kind, links.id AS id, links.id AS link_id,
+			bm25(links_fts, 2.0, 1.0, 1.0) AS rank,
+			snippet(links_fts, -1, ?, ?, '…', ?) AS snippet
+			FROM links_fts JOIN links ON links.id = links_fts.rowid
+			WHERE links_fts MATCH ? AND links.deleted_at IS NULL`,
+	},
+	{
+		kind:    SearchKindComment,
+		columns: map[string]bool{"body": true},
+		sql: `SELECT 'comment' AS kind, comments.id AS id, comments.link_id AS link_id,
+			bm25(comments_fts) AS rank,
+			snippet(comments_fts, 0, ?, ?, '…', ?) AS snippet
+			FROM comments_fts JOIN comments ON comments.id = comments_fts.rowid
+			JOIN links ON links.id = comments.link_id
+			WHERE comments_fts MATCH ? AND links.deleted_at IS NULL`,
+	},
+}
+
+// fieldAliases maps the field names accepted in queries to indexed columns.
+var fieldAliases = map[string]string{
+	"title":       "description",
+	"description": "description",
+	"url":         "url",
+	"site":        "url",
+	"body":        "body",
+	"comment":     "body",
+}
+
+// Search runs a full-text search over links and comments and returns a page
+// of results, most relevant first, and whether there are more.
+//
+// The query is a list of terms that must all match. A term may be a word, a
+// "quoted phrase", a prefix ending in *, or any of those qualified with a field
+// as in title:graphql or url:"example.com". Terms separated by OR need only one
+// of them to match.
+func (db *DB) Search(query string, limit, offset int) ([]SearchResult, bool, error) {
+	groups := parseSearchQuery(query)
+	if len(groups) == 0 {
+		return nil, false, ErrNoSearchTerms
+	}
+
+	var (
+		selects []string
+		args    []interface{}
+	)
+	for _, table := range searchTables {
+		match, ok := table.matchExpression(groups)
+		if !ok {
+			continue
+		}
+		selects = append(selects, table.sql)
+		args = append(args, HighlightStart, HighlightEnd, snippetTokens, match)
+	}
+	if len(selects) == 0 {
+		return []SearchResult{}, false, nil
+	}
+
+	sql := strings.Join(selects, " UNION ALL ") + " ORDER BY rank, id LIMIT ? OFFSET ?"
+	args = append(args, limit+1, offset)
+	var results []SearchResult
+	if err := db.Raw(sql, args...).Scan(&results).Error; err != nil {
+		return nil, false, errors.Wrap(err, "unable to search")
+	}
+	more := len(results) > limit
+	if more {
+		results = results[:limit]
+	}
+	return results, more, nil
+}
+
+// searchTerm is a single word or phrase of a search query.
+type searchTerm struct {
+	column string
+	text   string
+	prefix bool
+}
+
+// parseSearchQuery splits a query into groups of alternatives. Every group has
+// to match, and a group matches if any one of its terms does.
+func parseSearchQuery(query string) [][]searchTerm {
+	var (
+		groups  [][]searchTerm
+		pending bool // the previous token was OR
+	)
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		var term searchTerm
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '"' && runes[i] != ':' {
+			i++
+		}
+		word := string(runes[start:i])
+		if i < len(runes) && runes[i] == ':' {
+			if column, ok := fieldAliases[strings.ToLower(word)]; ok {
+				term.column = column
+				i++
+				start = i
+				word = ""
+			} else {
+				// Not a field, so the colon is part of the word.
+				for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '"' {
+					i++
+				}
+				word = string(runes[start:i])
+			}
+		}
+		if i < len(runes) && runes[i] == '"' && word == "" {
+			i++
+			start = i
+			for i < len(runes) && runes[i] != '"' {
+				i++
+			}
+			term.text = string(runes[start:i])
+			i++ // closing quote
+			if i < len(runes) && runes[i] == '*' {
+				term.prefix = true
+				i++
+			}
+		} else {
+			for i < len(runes) && !unicode.IsSpace(runes[i]) {
+				i++
+			}
+			word = string(runes[start:i])
+			if word == "OR" && term.column == "" {
+				pending = len(groups) > 0
+				continue
+			}
+			if strings.HasSuffix(word, "*") {
+				term.prefix = true
+				word = strings.TrimRight(word, "*")
+			}
+			term.text = word
+		}
+
+		if strings.IndexFunc(term.text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) < 0 {
+			continue
+		}
+		if pending {
+			groups[len(groups)-1] = append(groups[len(groups)-1], term)
+			pending = false
+		} else {
+			groups = append(groups, []searchTerm{term})
+		}
+	}
+	return groups
+}
+
+// matchExpression builds the FTS5 MATCH expression for the table. It returns
+// false if the query can't match anything in the table because a required
+// term is qualified with a field the table doesn't have.
+func (t searchTable) matchExpression(groups [][]searchTerm) (string, bool) {
+	var clauses []string
+	for _, group := range groups {
+		var alternatives []string
+		for _, term := range group {
+			if term.column != "" && !t.columns[term.column] {
+				continue
+			}
+			alternatives = append(alternatives, term.expression())
+		}
+		if len(alternatives) == 0 {
+			return "", false
+		}
+		clauses = append(clauses, "("+strings.Join(alternatives, " OR ")+")")
+	}
+	return strings.Join(clauses, " AND "), true
+}
+
+func (t searchTerm) expression() string {
+	expr := quoteSearchTerm(t.text)
+	if t.prefix {
+		expr += "*"
+	}
+	if t.column != "" {
+		expr = t.column + " : " + expr
+	}
+	return expr
+}
+
+// quoteSearchTerm quotes text as an FTS5 string so that none of its
+// characters are taken as query syntax.
+func quoteSearchTerm(text string) string {
+	return `"` + strings.Replace(text, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,54 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package db
+
+import (
+	"testing"
+
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+func searchLinkIDs(t *testing.T, database *DB, query string) []uint {
+	t.Helper()
+	results, _, err := database.Search(query, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint
+	for _, result := range results {
+		if result.Kind == SearchKindLink {
+			ids = append(ids, result.ID)
+		}
+	}
+	return ids
+}
+
+func TestSearchMatchesLinkBodies(t *testing.T) {
+	database := newTestDB(t)
+	user := createTestUser(t, database, "alice")
+	link := &model.Link{Description: "Ask: editors", PosterID: user.ID, Type: model.PostTypeAsk, Body: "Which editor handles **tabs** best?"}
+	if err := database.CreateLink(link); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"tabs", "body:tabs", "editor*"} {
+		if ids := searchLinkIDs(t, database, query); len(ids) != 1 || ids[0] != link.ID {
+			t.Errorf("search %q found links %v, want [%d]", query, ids, link.ID)
+		}
+	}
+	if ids := searchLinkIDs(t, database, "title:tabs"); len(ids) != 0 {
+		t.Errorf("search title:tabs found links %v, want none", ids)
+	}
+
+	link.Body = "Which editor handles spaces best?"
+	if err := database.UpdateLink(link); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchLinkIDs(t, database, "tabs"); len(ids) != 0 {
+		t.Errorf("search for the old body found links %v, want none", ids)
+	}
+	if ids := searchLinkIDs(t, database, "spaces"); len(ids) != 1 {
+		t.Errorf("search for the new body found links %v, want [%d]", ids, link.ID)
+	}
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"time"
+
+	"github.com/leggettc18/hackernews-clone-api/model"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Sample data inserted by Seed. PosterID, UserID and LinkID refer to the
+// position of the related fixture rather than a database ID.
+var (
+	passwordHash, _ = bcrypt.GenerateFromPassword(
+		[]byte("password"),
+		bcrypt.DefaultCost,
+	)
+	longForm      = "Jan 2, 2006 at 3:04pm (MST)"
+	sampleTime, _ = time.Parse(longForm, "Feb 3, 2013 at 7:54pm (PST)")
+	users         = []model.User{
+		{
+			ID:             0,
+			Name:           "Admin",
+			Email:          "admin@example.com",
+			HashedPassword: passwordHash,
+			Role:           model.RoleAdmin,
+		},
+	}
+	links = []model.Link{
+		{
+			//ID:          0,
+			CreatedAt:   sampleTime,
+			Url:         "https://www.howtographql.com",
+			Description: "Fullstack tutorial for Graphql",
+			PosterID:    0,
+			Type:        model.PostTypeLink,
+		},
+		{
+			//ID:          1,
+			CreatedAt:   sampleTime,
+			Url:         "https://www.howtographql.com",
+			Description: "Fullstack tutorial for Graphql",
+			PosterID:    0,
+			Type:        model.PostTypeLink,
+		},
+		{
+			//ID:          2,
+			CreatedAt:   sampleTime,
+			Url:         "https://www.howtographql.com",
+			Description: "Fullstack tutorial for Graphql",
+			PosterID:    0,
+			Type:        model.PostTypeLink,
+		},
+		{
+			//ID:          3,
+			CreatedAt:   sampleTime,
+			Url:         "https://www.howtographql.com",
+			Description: "Fullstack tutorial for Graphql",
+			PosterID:    0,
+			Type:        model.PostTypeLink,
+		},
+		{
+			//ID:          4,
+			CreatedAt:   sampleTime,
+			Url:         "https://www.howtographql.com",
+			Description: "Fullstack tutorial for Graphql",
+			PosterID:    0,
+			Type:        model.PostTypeLink,
+		},
+		{
+			//ID:          5,
+			CreatedAt:   sampleTime,
+			Url:         "https://www.howtographql.com",
+			Description: "Fullstack tutorial for Graphql",
+			PosterID:    0,
+			Type:        model.PostTypeLink,
+		},
+		{
+			//ID:          6,
+			CreatedAt:   sampleTime,
+			Url:         "https://www.howtographql.com",
+			Description: "Fullstack tutorial for Graphql",
+			PosterID:    0,
+			Type:        model.PostTypeLink,
+		},
+		{
+			//ID:          7,
+			CreatedAt:   sampleTime,
+			Url:         "https://www.howtographql.com",
+			Description: "Fullstack tutorial for Graphql",
+			PosterID:    0,
+			Type:        model.PostTypeLink,
+		},
+	}
+	votes = []model.Vote{
+		{
+			UserID:    0,
+			LinkID:    0,
+			Direction: model.Upvote,
+		},
+	}
+)
+
+// Seed inserts the sample users, links and votes. It is a no-op if the
+// sample admin account already exists, so it is safe to run on every start.
+func (db *DB) Seed() error {
+	if err := db.Where("email = ?", users[0].Email).First(&model.User{}).Error; err == nil {
+		return nil
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return errors.Wrap(tx.Error, "unable to begin seeding")
+	}
+
+	// Fixtures reference each other by position, so remember the IDs the
+	// database actually assigned.
+	userIDs := make([]uint, len(users))
+	for i, user := range users {
+		user.ID = 0
+		if err := tx.Create(&user).Error; err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "unable to seed users")
+		}
+		userIDs[i] = user.ID
+	}
+
+	linkIDs := make([]uint, len(links))
+	for i, link := range links {
+		link.PosterID = userIDs[link.PosterID]
+		if err := tx.Create(&link).Error; err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "unable to seed links")
+		}
+		linkIDs[i] = link.ID
+	}
+
+	for _, vote := range votes {
+		vote.ID = 0
+		vote.UserID = userIDs[vote.UserID]
+		vote.LinkID = linkIDs[vote.LinkID]
+		if err := tx.Create(&vote).Error; err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "unable to seed votes")
+		}
+	}
+
+	return errors.Wrap(tx.Commit().Error, "unable to commit seed data")
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/leggettc18/hackernews-clone-api/auth"
+	"github.com/leggettc18/hackernews-clone-api/model"
+	"github.com/pkg/errors"
+)
+
+// ErrTokenRevoked is returned for access tokens that were revoked before they expired.
+var ErrTokenRevoked = &auth.AuthenticationError{Message: "token has been revoked"}
+
+// ErrInvalidToken is returned for access tokens that are malformed, forged or
+// issued to users that no longer exist.
+var ErrInvalidToken = &auth.AuthenticationError{Message: "invalid access token"}
+
+// ErrTokenExpired is returned for access tokens past their expiry.
+var ErrTokenExpired = &auth.AuthenticationError{Message: "access token expired"}
+
+// Claims are the claims carried by an access token.
+type Claims struct {
+	UserID uint `json:"ID"`
+	// Generation is the user's TokenGeneration when the token was issued.
+	Generation int `json:"gen"`
+	jwt.StandardClaims
+}
+
+// GenerateToken returns a signed, short-lived access token identifying the given user.
+func (db *DB) GenerateToken(user *model.User) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		UserID:     user.ID,
+		Generation: user.TokenGeneration,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Issuer:    db.config.Auth.Issuer,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(db.config.Auth.AccessTokenTTL).Unix(),
+		},
+	})
+	tokenString, errToken := token.SignedString([]byte(db.config.Auth.JWTSecret))
+	if errToken != nil {
+		return "", errToken
+	}
+	return tokenString, nil
+}
+
+// ParseToken verifies the signature, expiry and issuer of an access token
+// and returns its claims. It does not check whether the token was revoked.
+// Tokens failing the checks are reported as ErrTokenExpired or ErrInvalidToken.
+func (db *DB) ParseToken(tokenString string) (*Claims, error) {
+	var claims Claims
+	// decode token with the secret it was encoded with
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return []byte(db.config.Auth.JWTSecret), nil
+	})
+	if validation, ok := err.(*jwt.ValidationError); ok && validation.Errors&jwt.ValidationErrorExpired != 0 {
+		return nil, ErrTokenExpired
+	}
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	// Tokens with an unexpected issuer or missing claims weren't issued by us.
+	if !claims.VerifyIssuer(db.config.Auth.Issuer, true) || claims.Id == "" || claims.ExpiresAt == 0 {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// getUserFromClaims returns the user an access token with the given claims was
+// issued to, provided the token hasn't been revoked.
+func (db *DB) getUserFromClaims(claims *Claims) (*model.User, error) {
+	revoked, err := db.IsTokenRevoked(claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	user, err := db.GetUserById(claims.UserID)
+	if IsNotFound(err) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if claims.Generation != user.TokenGeneration {
+		return nil, ErrTokenRevoked
+	}
+	return user, nil
+}
+
+// Authenticate returns the principal an access token was issued to, provided
+// the token is valid and hasn't been revoked.
+func (db *DB) Authenticate(tokenString string) (*auth.Principal, error) {
+	claims, err := db.ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	user, err := db.getUserFromClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &auth.Principal{
+		UserID:         claims.UserID,
+		Roles:          auth.ImpliedRoles(user.Role),
+		TokenID:        claims.Id,
+		TokenExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+// RevokeToken adds the access token with the given ID to the revocation list
+// until it expires. Entries for tokens that have since expired are pruned
+// along the way.
+func (db *DB) RevokeToken(jti string, expiresAt time.Time) error {
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&model.RevokedToken{}).Error; err != nil {
+		return errors.Wrap(err, "unable to prune revoked tokens")
+	}
+	revoked := model.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+	}
+	return errors.Wrap(db.Save(&revoked).Error, "unable to revoke token")
+}
+
+// IsTokenRevoked reports whether the access token with the given ID was revoked.
+func (db *DB) IsTokenRevoked(jti string) (bool, error) {
+	var count int
+	err := db.Model(&model.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, errors.Wrap(err, "unable to check token revocation")
+}
+
+// randomToken returns n random bytes, hex encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "unable to generate token")
+	}
+	return hex.EncodeToString(b), nil
+}
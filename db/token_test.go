@@ -0,0 +1,58 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package db
+
+import "testing"
+
+func TestRevokeAllSessionsInvalidatesEarlierTokensOnly(t *testing.T) {
+	database := newTestDB(t)
+	user := createTestUser(t, database, "alice")
+
+	before, err := database.GenerateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Authenticate(before); err != nil {
+		t.Fatalf("fresh token rejected: %v", err)
+	}
+
+	if err := database.RevokeAllSessions(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Log in again right away, most likely within the same second.
+	user, err = database.GetUserById(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := database.GenerateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := database.Authenticate(before); err != ErrTokenRevoked {
+		t.Errorf("token issued before the revocation: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := database.Authenticate(after); err != nil {
+		t.Errorf("token issued after the revocation rejected: %v", err)
+	}
+}
+
+func TestRevokedTokenIsRejected(t *testing.T) {
+	database := newTestDB(t)
+	user := createTestUser(t, database, "alice")
+	token, err := database.GenerateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err := database.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.RevokeToken(principal.TokenID, principal.TokenExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Authenticate(token); err != ErrTokenRevoked {
+		t.Errorf("got %v, want ErrTokenRevoked", err)
+	}
+}
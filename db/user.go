@@ -1,54 +1,77 @@
 package db
 
 import (
-	"github.com/dgrijalva/jwt-go"
-	"github.com/jinzhu/gorm"
+	"strings"
+
 	"github.com/leggettc18/hackernews-clone-api/model"
 	"github.com/pkg/errors"
 )
 
-// GetUserByEmail returns the user with the specified email address from the database.
+// Errors returned by CreateUser for emails and names already in use, ignoring case.
+var (
+	ErrEmailTaken = errors.New("email is already taken")
+	ErrNameTaken  = errors.New("name is already taken")
+)
+
+// GetUserByEmail returns the user with the specified email address from the
+// database, ignoring case.
 func (db *DB) GetUserByEmail(email string) (*model.User, error) {
 	var user model.User
-	if err := db.First(&user, model.User{Email: email}).Error; err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			return nil, errors.Wrap(err, "unable to get user")
-		}
+	if err := db.Where("email = ? COLLATE NOCASE", email).First(&user).Error; err != nil {
+		return nil, errors.Wrap(err, "unable to get user")
 	}
 	return &user, nil
 }
 
 func (db *DB) GetUserById(id uint) (*model.User, error) {
 	var user model.User
-	if err := db.First(&user, model.User{ID: id}).Error; err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			return nil, errors.Wrap(err, "unable to get user")
-		}
+	// A model.User condition would be dropped for ID 0, returning the first user.
+	if err := db.Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, errors.Wrap(err, "unable to get user")
 	}
 	return &user, nil
 }
 
-func (db *DB) GetUserFromToken(tokenString string) (*model.User, error) {
-	// decode token with the secret it was encoded with
-	tokenObj, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte("verysecret"), nil
-	})
-	if err != nil {
-		return nil, err
+// GetUserByName returns the user with the given name, ignoring case.
+func (db *DB) GetUserByName(name string) (*model.User, error) {
+	var user model.User
+	if err := db.Where("name = ? COLLATE NOCASE", name).First(&user).Error; err != nil {
+		return nil, errors.Wrap(err, "unable to get user")
 	}
-	// get user ID from the map we encoded in the token
-	userID, ok := tokenObj.Claims.(jwt.MapClaims)["ID"].(float64)
-	if !ok {
-		return nil, errors.New("GetUserIDFromToken error: type conversion in claims")
+	return &user, nil
+}
+
+// CreateUser inserts a new user into the database. Users get the user role
+// unless given another. It returns ErrEmailTaken or ErrNameTaken if another
+// user has the same email or name.
+func (db *DB) CreateUser(user *model.User) error {
+	if user.Role == "" {
+		user.Role = model.RoleUser
 	}
-	user, err := db.GetUserById(uint(userID))
-	if err != nil {
-		return nil, err
+	err := db.Create(user).Error
+	if err != nil && isUniqueViolation(err) {
+		switch {
+		case strings.Contains(err.Error(), "users.email"):
+			return ErrEmailTaken
+		case strings.Contains(err.Error(), "users.name"):
+			return ErrNameTaken
+		}
 	}
-	return user, nil
+	return errors.Wrap(err, "unable to create user")
 }
 
-// CreateUser inserts a new user into the database.
-func (db *DB) CreateUser(user *model.User) error {
-	return db.Create(user).Error
+// SetUserRole changes the role of the user.
+func (db *DB) SetUserRole(user *model.User, role string) error {
+	if err := db.Model(user).Update("role", role).Error; err != nil {
+		return errors.Wrap(err, "unable to update user role")
+	}
+	user.Role = role
+	return nil
+}
+
+// GetUsersByIds returns the users with the given IDs in one query. Users that
+// don't exist are left out, and the order is unspecified.
+func (db *DB) GetUsersByIds(ids []uint) ([]*model.User, error) {
+	var users []*model.User
+	return users, errors.Wrap(db.Where("id IN (?)", ids).Find(&users).Error, "unable to get users")
 }
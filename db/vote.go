@@ -1,20 +1,140 @@
 package db
 
 import (
+	"math"
+	"strings"
+
+	"github.com/jinzhu/gorm"
 	"github.com/leggettc18/hackernews-clone-api/model"
 	"github.com/pkg/errors"
 )
 
+// ErrDuplicateVote is returned when a user votes on a link the same way twice.
+var ErrDuplicateVote = errors.New("already voted on this link")
+
 func (db *DB) GetVoteById(id uint) (*model.Vote, error) {
 	var vote model.Vote
 	return &vote, errors.Wrap(db.First(&vote, id).Error, "unable to get vote")
 }
 
+// GetVoteByUserAndLink returns the vote the user cast on the link.
+func (db *DB) GetVoteByUserAndLink(userId, linkId uint) (*model.Vote, error) {
+	var vote model.Vote
+	return &vote, errors.Wrap(db.Where("user_id = ? AND link_id = ?", userId, linkId).First(&vote).Error, "unable to get vote")
+}
+
 func (db *DB) GetVotesByLinkId(linkId uint) ([]*model.Vote, error) {
 	var votes []*model.Vote
-	return votes, errors.Wrap(db.Where("link_id = ?", linkId).Find(&links).Error, "unable to get votes")
+	return votes, errors.Wrap(db.Where("link_id = ?", linkId).Find(&votes).Error, "unable to get votes")
+}
+
+// VotesPage returns a keyset page of the votes on a link, and whether there
+// are more votes past the end of the page.
+func (db *DB) VotesPage(linkId uint, page KeysetPage) ([]*model.Vote, bool, error) {
+	scope, limit, reversed := page.apply(db.Where("link_id = ?", linkId), "id")
+	var votes []*model.Vote
+	if err := scope.Find(&votes).Error; err != nil {
+		return nil, false, errors.Wrap(err, "unable to get votes")
+	}
+	more := len(votes) > limit
+	if more {
+		votes = votes[:limit]
+	}
+	if reversed {
+		for i, j := 0, len(votes)-1; i < j; i, j = i+1, j-1 {
+			votes[i], votes[j] = votes[j], votes[i]
+		}
+	}
+	return votes, more, nil
+}
+
+// VoteFilter narrows down a list of votes.
+type VoteFilter struct {
+	// UserID only matches votes cast by this user, if not zero.
+	UserID uint
+	// Direction only matches votes in this direction, if not zero.
+	Direction int
 }
 
+// ListVotes returns the votes matching the filter, newest first. First limits
+// the number of votes returned, zero meaning no limit.
+func (db *DB) ListVotes(filter VoteFilter, first, skip int) ([]*model.Vote, error) {
+	scope := db.filterVotes(filter).Order("id DESC")
+	if first > 0 {
+		scope = scope.Limit(first)
+	}
+	if skip > 0 {
+		if first <= 0 {
+			scope = scope.Limit(math.MaxInt64)
+		}
+		scope = scope.Offset(skip)
+	}
+	var votes []*model.Vote
+	return votes, errors.Wrap(scope.Find(&votes).Error, "unable to get votes")
+}
+
+// CountVotes returns the number of votes matching the filter.
+func (db *DB) CountVotes(filter VoteFilter) (int, error) {
+	var count int
+	return count, errors.Wrap(db.filterVotes(filter).Count(&count).Error, "unable to count votes")
+}
+
+func (db *DB) filterVotes(filter VoteFilter) *gorm.DB {
+	scope := db.Model(&model.Vote{})
+	if filter.UserID != 0 {
+		scope = scope.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Direction != 0 {
+		scope = scope.Where("direction = ?", filter.Direction)
+	}
+	return scope
+}
+
+// CountVotesByLinkId returns the number of votes on a link.
+func (db *DB) CountVotesByLinkId(linkId uint) (int, error) {
+	var count int
+	return count, errors.Wrap(db.Model(&model.Vote{}).Where("link_id = ?", linkId).Count(&count).Error, "unable to count votes")
+}
+
+// CreateVote inserts a new vote. It returns ErrDuplicateVote if the user already
+// voted on the link; the unique index on (user_id, link_id) backs this up
+// against concurrent requests.
 func (db *DB) CreateVote(vote *model.Vote) error {
-	return db.Create(&vote).Error
+	if vote.Direction == 0 {
+		vote.Direction = model.Upvote
+	}
+	err := db.Create(vote).Error
+	if err != nil && isUniqueViolation(err) {
+		return ErrDuplicateVote
+	}
+	return errors.Wrap(err, "unable to create vote")
+}
+
+// UpdateVoteDirection flips an existing vote to the given direction.
+func (db *DB) UpdateVoteDirection(vote *model.Vote, direction int) error {
+	if err := db.Model(vote).Update("direction", direction).Error; err != nil {
+		return errors.Wrap(err, "unable to update vote")
+	}
+	vote.Direction = direction
+	return nil
+}
+
+// DeleteVote removes a vote, retracting it.
+func (db *DB) DeleteVote(vote *model.Vote) error {
+	return errors.Wrap(db.Delete(vote).Error, "unable to delete vote")
+}
+
+// GetUserKarma returns the sum of the votes other users cast on the user's links.
+func (db *DB) GetUserKarma(userId uint) (int, error) {
+	var result struct {
+		Karma int
+	}
+	err := db.Raw(`SELECT COALESCE(SUM(votes.direction), 0) AS karma
+		FROM votes JOIN links ON links.id = votes.link_id
+		WHERE links.poster_id = ? AND votes.user_id != ?`, userId, userId).Scan(&result).Error
+	return result.Karma, errors.Wrap(err, "unable to get karma")
+}
+
+func isUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
 }
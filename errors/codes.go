@@ -0,0 +1,136 @@
+package errors
+
+import "fmt"
+
+// Code classifies an error for clients. It is sent as the code extension of
+// GraphQL errors.
+type Code string
+
+const (
+	// Unauthenticated means the request needs a valid access token.
+	Unauthenticated Code = "UNAUTHENTICATED"
+	// Forbidden means the user may not do what they asked.
+	Forbidden Code = "FORBIDDEN"
+	// NotFound means something the request refers to doesn't exist.
+	NotFound Code = "NOT_FOUND"
+	// Validation means the request's arguments were rejected.
+	Validation Code = "VALIDATION"
+	// Conflict means the request clashes with the current state, such as
+	// voting twice.
+	Conflict Code = "CONFLICT"
+	// Internal means the server failed. The details are logged, not shown.
+	Internal Code = "INTERNAL"
+)
+
+// Error is an error that is safe to show clients.
+type Error struct {
+	Code    Code
+	Message string
+	// Fields are the arguments a validation error is about, each a
+	// FieldError, possibly wrapped by WithIndex.
+	Fields Errors
+	// Extra extensions sent along with the code and fields.
+	Extra map[string]interface{}
+}
+
+// New returns an error with the given code and message.
+func New(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// With adds an extension to the error and returns it.
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Extra == nil {
+		e.Extra = map[string]interface{}{}
+	}
+	e.Extra[key] = value
+	return e
+}
+
+// Extensions is included in the GraphQL error.
+func (e *Error) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.Code}
+	for key, value := range e.Extra {
+		extensions[key] = value
+	}
+	if len(e.Fields) > 0 {
+		fields := make([]map[string]interface{}, 0, len(e.Fields))
+		for _, err := range e.Fields {
+			fields = append(fields, fieldExtension(err))
+		}
+		extensions["fields"] = fields
+	}
+	return extensions
+}
+
+// FieldError reports an invalid argument.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Field returns a FieldError for the named argument.
+func Field(field, format string, args ...interface{}) *FieldError {
+	return &FieldError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Invalid returns a validation error for the operation op listing every one
+// of errs, or nil if there are none. This lets checks collect their failures
+// and report them all at once.
+func Invalid(op string, errs Errors) error {
+	return withFields(Validation, op, errs)
+}
+
+// Conflicting returns a conflict error for the operation op about the given
+// fields, such as an email address that is already taken, or nil if there are
+// none.
+func Conflicting(op string, errs Errors) error {
+	return withFields(Conflict, op, errs)
+}
+
+func withFields(code Code, op string, errs Errors) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	message := errs.Error()
+	if len(errs) == 1 {
+		message = errs[0].Error()
+	}
+	if op != "" {
+		message = op + ": " + message
+	}
+	return &Error{Code: code, Message: message, Fields: errs}
+}
+
+// fieldExtension describes one of the fields of a validation error. Indexes
+// added by WithIndex point at the offending item of a list argument.
+func fieldExtension(err error) map[string]interface{} {
+	extension := map[string]interface{}{}
+	var indexes []int
+	for {
+		indexed, ok := err.(indexedError)
+		if !ok {
+			break
+		}
+		indexes = append(indexes, indexed.index)
+		err = indexed.cause
+	}
+	if field, ok := err.(*FieldError); ok {
+		extension["field"] = field.Field
+		extension["message"] = field.Message
+	} else {
+		extension["message"] = err.Error()
+	}
+	if len(indexes) > 0 {
+		extension["index"] = indexes
+	}
+	return extension
+}
@@ -0,0 +1,154 @@
+// Package linkurl validates and normalizes the urls links point to, so that
+// the same page submitted twice is recognized as such.
+package linkurl
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// MaxLength is the longest url accepted.
+const MaxLength = 2048
+
+// defaultScheme is assumed for urls submitted without one.
+const defaultScheme = "https"
+
+// schemePrefix matches a url that starts with a scheme, as opposed to one that
+// only has a url further along, as in example.com/login?next=https://....
+var schemePrefix = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+
+// trackingParams are query parameters that only identify where a visitor came
+// from. Parameters starting with utm_ are stripped too.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"ref_src": true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Error explains why a url was rejected.
+type Error struct {
+	// Reason completes "url ...", as in "must use http or https".
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return "url " + e.Reason
+}
+
+// Normalize checks that raw is an absolute http or https url and returns it in
+// a canonical form:
+//
+//   - https is assumed if there is no scheme
+//   - the scheme and host are lower-cased and default ports removed
+//   - tracking parameters are removed and the rest sorted
+//   - the fragment and any trailing slash are removed
+//
+// The path keeps its escaping, so that an escaped slash stays part of a path
+// segment. http and https urls stay distinct; see Equivalents.
+func Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case raw == "":
+		return "", &Error{"can't be empty"}
+	case len(raw) > MaxLength:
+		return "", &Error{"is too long"}
+	case strings.ContainsAny(raw, " \t\r\n"):
+		return "", &Error{"can't contain spaces"}
+	}
+	if !schemePrefix.MatchString(raw) {
+		raw = defaultScheme + "://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", &Error{"is malformed"}
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if _, ok := defaultPorts[u.Scheme]; !ok {
+		return "", &Error{"must use http or https"}
+	}
+	if u.User != nil {
+		return "", &Error{"can't contain a username or password"}
+	}
+
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if host == "" {
+		return "", &Error{"must have a host"}
+	}
+	if net.ParseIP(host) == nil && !strings.Contains(strings.Trim(host, "."), ".") {
+		return "", &Error{"must have a domain name"}
+	}
+	host = strings.TrimSuffix(host, ".")
+	if strings.Contains(host, ":") {
+		// IPv6 addresses need their brackets back.
+		host = "[" + host + "]"
+	}
+	if port != "" && port != defaultPorts[u.Scheme] {
+		host += ":" + port
+	}
+	u.Host = host
+
+	escapedPath := strings.TrimRight(u.EscapedPath(), "/")
+	if u.Path, err = url.PathUnescape(escapedPath); err != nil {
+		return "", &Error{"is malformed"}
+	}
+	u.RawPath = escapedPath
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	query := u.Query()
+	for param := range query {
+		if trackingParams[strings.ToLower(param)] || strings.HasPrefix(strings.ToLower(param), "utm_") {
+			query.Del(param)
+		}
+	}
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// Equivalents returns a normalized url together with the urls that most likely
+// point to the same page: the same url over the other one of http and https,
+// unless it has an explicit port.
+func Equivalents(normalized string) []string {
+	u, err := url.Parse(normalized)
+	if err != nil || u.Port() != "" {
+		return []string{normalized}
+	}
+	other := *u
+	if u.Scheme == "https" {
+		other.Scheme = "http"
+	} else {
+		other.Scheme = "https"
+	}
+	return []string{normalized, other.String()}
+}
+
+// Domain returns the lower-cased host of a url without any "www." prefix, or
+// "" if it has none. It accepts urls that weren't normalized too.
+func Domain(rawURL string) string {
+	if !schemePrefix.MatchString(rawURL) {
+		rawURL = defaultScheme + "://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
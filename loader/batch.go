@@ -0,0 +1,95 @@
+package loader
+
+import (
+	"sync"
+	"time"
+)
+
+// fetchFunc loads the values for a batch of IDs. IDs that don't exist are
+// left out of the result.
+type fetchFunc func(ids []uint) (map[uint]interface{}, error)
+
+// batchLoader collects the IDs requested within a short window and fetches
+// them together. Every ID is fetched at most once, and later loads of it
+// return the same result.
+type batchLoader struct {
+	fetch    fetchFunc
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	results map[uint]*result
+	pending *batch
+}
+
+type result struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+type batch struct {
+	ids     []uint
+	results []*result
+}
+
+func newBatchLoader(fetch fetchFunc, wait time.Duration, maxBatch int) *batchLoader {
+	return &batchLoader{
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+		results:  map[uint]*result{},
+	}
+}
+
+// load returns the value for id, or nil if it doesn't exist, blocking until
+// the batch it is part of has been fetched.
+func (l *batchLoader) load(id uint) (interface{}, error) {
+	l.mu.Lock()
+	res, ok := l.results[id]
+	if !ok {
+		res = &result{done: make(chan struct{})}
+		l.results[id] = res
+		if l.pending == nil {
+			b := &batch{}
+			l.pending = b
+			time.AfterFunc(l.wait, func() { l.dispatch(b) })
+		}
+		l.pending.ids = append(l.pending.ids, id)
+		l.pending.results = append(l.pending.results, res)
+		if len(l.pending.ids) >= l.maxBatch {
+			b := l.pending
+			l.pending = nil
+			go l.run(b)
+		}
+	}
+	l.mu.Unlock()
+
+	<-res.done
+	return res.value, res.err
+}
+
+// dispatch runs the batch once its wait is over, unless it filled up and ran
+// already.
+func (l *batchLoader) dispatch(b *batch) {
+	l.mu.Lock()
+	if l.pending != b {
+		l.mu.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mu.Unlock()
+	l.run(b)
+}
+
+func (l *batchLoader) run(b *batch) {
+	values, err := l.fetch(b.ids)
+	for i, res := range b.results {
+		if err != nil {
+			res.err = err
+		} else {
+			res.value = values[b.ids[i]]
+		}
+		close(res.done)
+	}
+}
@@ -0,0 +1,135 @@
+package loader
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFetch returns each ID doubled, leaving out the IDs in missing, and
+// records the batches it was asked for.
+type fakeFetch struct {
+	missing map[uint]bool
+	err     error
+
+	mu      sync.Mutex
+	batches [][]uint
+}
+
+func (f *fakeFetch) fetch(ids []uint) (map[uint]interface{}, error) {
+	f.mu.Lock()
+	f.batches = append(f.batches, append([]uint(nil), ids...))
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	values := map[uint]interface{}{}
+	for _, id := range ids {
+		if !f.missing[id] {
+			values[id] = id * 2
+		}
+	}
+	return values, nil
+}
+
+func (f *fakeFetch) batchSizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sizes := make([]int, len(f.batches))
+	for i, b := range f.batches {
+		sizes[i] = len(b)
+	}
+	sort.Ints(sizes)
+	return sizes
+}
+
+// loadAll loads the IDs concurrently and returns the values in order.
+func loadAll(l *batchLoader, ids []uint) ([]interface{}, []error) {
+	values := make([]interface{}, len(ids))
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id uint) {
+			defer wg.Done()
+			values[i], errs[i] = l.load(id)
+		}(i, id)
+	}
+	wg.Wait()
+	return values, errs
+}
+
+func TestBatchLoader(t *testing.T) {
+	tests := []struct {
+		name     string
+		ids      []uint
+		maxBatch int
+		want     []int
+	}{
+		{"one id", []uint{1}, 100, []int{1}},
+		{"concurrent ids share a batch", []uint{1, 2, 3, 4, 5}, 100, []int{5}},
+		{"repeated ids are fetched once", []uint{1, 2, 1, 2, 1}, 100, []int{2}},
+		{"full batches are split", []uint{1, 2, 3, 4, 5}, 2, []int{1, 2, 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := &fakeFetch{}
+			// A long wait keeps every load in the first batch.
+			l := newBatchLoader(f.fetch, 50*time.Millisecond, test.maxBatch)
+			values, errs := loadAll(l, test.ids)
+			for i, id := range test.ids {
+				if errs[i] != nil || values[i] != id*2 {
+					t.Errorf("load(%d) = %v, %v, want %d", id, values[i], errs[i], id*2)
+				}
+			}
+			got := f.batchSizes()
+			if len(got) != len(test.want) {
+				t.Fatalf("got batches of %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("got batches of %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchLoaderCaches(t *testing.T) {
+	f := &fakeFetch{}
+	l := newBatchLoader(f.fetch, time.Millisecond, 100)
+	for i := 0; i < 3; i++ {
+		if value, err := l.load(7); err != nil || value != uint(14) {
+			t.Fatalf("load(7) = %v, %v, want 14", value, err)
+		}
+	}
+	if got := f.batchSizes(); len(got) != 1 {
+		t.Errorf("got %d fetches, want 1", len(got))
+	}
+}
+
+func TestBatchLoaderMissing(t *testing.T) {
+	f := &fakeFetch{missing: map[uint]bool{2: true}}
+	l := newBatchLoader(f.fetch, 10*time.Millisecond, 100)
+	values, errs := loadAll(l, []uint{1, 2})
+	if errs[0] != nil || values[0] != uint(2) {
+		t.Errorf("load(1) = %v, %v, want 2", values[0], errs[0])
+	}
+	if errs[1] != nil || values[1] != nil {
+		t.Errorf("load(2) = %v, %v, want nil, nil", values[1], errs[1])
+	}
+}
+
+func TestBatchLoaderError(t *testing.T) {
+	failure := errors.New("database is down")
+	f := &fakeFetch{err: failure}
+	l := newBatchLoader(f.fetch, 10*time.Millisecond, 100)
+	_, errs := loadAll(l, []uint{1, 2})
+	for i, err := range errs {
+		if err != failure {
+			t.Errorf("load %d: got %v, want %v", i, err, failure)
+		}
+	}
+}
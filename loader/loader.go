@@ -0,0 +1,123 @@
+// Package loader batches and caches the users and links resolvers look up by
+// ID while executing a single request, so that resolving a list of nodes
+// takes one query per kind of node instead of one per node.
+package loader
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/leggettc18/hackernews-clone-api/db"
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+const (
+	// wait is how long a loader collects IDs before fetching them.
+	wait = 2 * time.Millisecond
+	// maxBatch is the most IDs fetched in one query.
+	maxBatch = 100
+)
+
+type contextKey int
+
+const loadersKey contextKey = iota
+
+// Loaders holds the loaders of one request. Values are cached for the whole
+// request, so a Loaders must not outlive it.
+type Loaders struct {
+	Users *UserLoader
+	Links *LinkLoader
+}
+
+// New returns a fresh set of loaders reading from the database.
+func New(database *db.DB) *Loaders {
+	return &Loaders{
+		Users: &UserLoader{database, newBatchLoader(fetchUsers(database), wait, maxBatch)},
+		Links: &LinkLoader{database, newBatchLoader(fetchLinks(database), wait, maxBatch)},
+	}
+}
+
+// Attach returns a copy of ctx carrying a fresh set of loaders.
+func Attach(ctx context.Context, database *db.DB) context.Context {
+	return context.WithValue(ctx, loadersKey, New(database))
+}
+
+// Middleware attaches a fresh set of loaders to every request.
+func Middleware(database *db.DB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(Attach(r.Context(), database)))
+	})
+}
+
+// For returns the loaders attached to ctx, or nil if there are none.
+func For(ctx context.Context) *Loaders {
+	loaders, _ := ctx.Value(loadersKey).(*Loaders)
+	return loaders
+}
+
+// UserLoader loads users by ID.
+type UserLoader struct {
+	db    *db.DB
+	batch *batchLoader
+}
+
+// Load returns the user with the given ID.
+func (l *UserLoader) Load(id uint) (*model.User, error) {
+	value, err := l.batch.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		// Look the user up on its own to get the usual not found error.
+		return l.db.GetUserById(id)
+	}
+	return value.(*model.User), nil
+}
+
+func fetchUsers(database *db.DB) fetchFunc {
+	return func(ids []uint) (map[uint]interface{}, error) {
+		users, err := database.GetUsersByIds(ids)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[uint]interface{}, len(users))
+		for _, user := range users {
+			values[user.ID] = user
+		}
+		return values, nil
+	}
+}
+
+// LinkLoader loads links by ID.
+type LinkLoader struct {
+	db    *db.DB
+	batch *batchLoader
+}
+
+// Load returns the link with the given ID.
+func (l *LinkLoader) Load(id uint) (*model.Link, error) {
+	value, err := l.batch.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		// Look the link up on its own to get the usual not found error.
+		return l.db.GetLinkByIdUnscoped(id)
+	}
+	return value.(*model.Link), nil
+}
+
+func fetchLinks(database *db.DB) fetchFunc {
+	return func(ids []uint) (map[uint]interface{}, error) {
+		links, err := database.GetLinksByIds(ids)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[uint]interface{}, len(links))
+		for _, link := range links {
+			values[link.ID] = link
+		}
+		return values, nil
+	}
+}
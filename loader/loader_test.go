@@ -0,0 +1,129 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package loader
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leggettc18/hackernews-clone-api/config"
+	"github.com/leggettc18/hackernews-clone-api/db"
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+func newTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "hackernews-clone-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(dir, "db.sqlite")
+	cfg.Auth.JWTSecret = "0123456789abcdef0123"
+	database, err := db.NewDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// countQueries counts the queries run against the users table.
+func countQueries(database *db.DB) *int {
+	var mu sync.Mutex
+	count := new(int)
+	database.Callback().Query().After("gorm:query").Register("loader_test:count", func(scope *gorm.Scope) {
+		if scope.TableName() == "users" {
+			mu.Lock()
+			*count++
+			mu.Unlock()
+		}
+	})
+	return count
+}
+
+func TestUserLoaderBatches(t *testing.T) {
+	database := newTestDB(t)
+	var ids []uint
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := &model.User{Email: name + "@example.com", Name: name}
+		if err := database.CreateUser(user); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.ID)
+	}
+	queries := countQueries(database)
+
+	loaders := New(database)
+	users := make([]*model.User, len(ids))
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id uint) {
+			defer wg.Done()
+			users[i], errs[i] = loaders.Users.Load(id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	for i, id := range ids {
+		if errs[i] != nil || users[i] == nil || users[i].ID != id {
+			t.Errorf("Load(%d) = %v, %v", id, users[i], errs[i])
+		}
+	}
+	if *queries != 1 {
+		t.Errorf("got %d queries, want 1", *queries)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	database := newTestDB(t)
+	loaders := New(database)
+	for _, id := range []uint{0, 1000} {
+		if user, err := loaders.Users.Load(id); !db.IsNotFound(err) {
+			t.Errorf("Users.Load(%d) = %v, %v, want a not found error", id, user, err)
+		}
+		if link, err := loaders.Links.Load(id); !db.IsNotFound(err) {
+			t.Errorf("Links.Load(%d) = %v, %v, want a not found error", id, link, err)
+		}
+	}
+}
+
+func TestLoadersArePerRequest(t *testing.T) {
+	database := newTestDB(t)
+	user := &model.User{Email: "alice@example.com", Name: "alice"}
+	if err := database.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	link := &model.Link{Description: "before", Url: "https://example.com", PosterID: user.ID}
+	if err := database.CreateLink(link); err != nil {
+		t.Fatal(err)
+	}
+
+	first := Attach(context.Background(), database)
+	if got, err := For(first).Links.Load(link.ID); err != nil || got.Description != "before" {
+		t.Fatalf("got %v, %v, want the link", got, err)
+	}
+
+	link.Description = "after"
+	if err := database.UpdateLink(link); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first request keeps what it saw; the next one sees the edit.
+	if got, _ := For(first).Links.Load(link.ID); got.Description != "before" {
+		t.Errorf("first request: got %q, want the cached %q", got.Description, "before")
+	}
+	second := Attach(context.Background(), database)
+	if got, err := For(second).Links.Load(link.ID); err != nil || got.Description != "after" {
+		t.Errorf("second request: got %v, %v, want %q", got, err, "after")
+	}
+}
@@ -2,35 +2,38 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"github.com/leggettc18/hackernews-clone-api/auth"
+	"github.com/leggettc18/hackernews-clone-api/config"
 	"github.com/leggettc18/hackernews-clone-api/db"
+	"github.com/leggettc18/hackernews-clone-api/loader"
 	"github.com/leggettc18/hackernews-clone-api/resolvers"
+	"github.com/leggettc18/hackernews-clone-api/ws"
 	"github.com/rs/cors"
 
-	//"errors"
-	//"fmt"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"strings"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
-	//"golang.org/x/crypto/bcrypt"
-
 	"github.com/graph-gophers/graphql-go"
-	"github.com/graph-gophers/graphql-go/relay"
-	"github.com/graph-gophers/graphql-transport-ws/graphqlws"
 )
 
-var (
-	opts = []graphql.SchemaOpt{graphql.UseStringDescriptions()}
-)
+// shutdownTimeout is how long requests in flight get to finish when the server
+// is stopped.
+const shutdownTimeout = 10 * time.Second
 
 var (
-	addr              = ":8081"
-	readHeaderTimeout = 1 * time.Second
-	writeTimeout      = 10 * time.Second
-	idleTimeout       = 90 * time.Second
-	maxHeaderBytes    = http.DefaultMaxHeaderBytes
+	// Resolve up to a full page of list items in parallel, so that the loaders
+	// can batch their lookups into one query.
+	opts = []graphql.SchemaOpt{graphql.UseStringDescriptions(), graphql.MaxParallelism(100)}
 )
 
 // Reads and parses the schema from file.
@@ -52,63 +55,217 @@ func parseSchema(path string, resolver interface{}) *graphql.Schema {
 	return parsedSchema
 }
 
+// Handles the "migrate" command: migrate up, migrate down [steps] or migrate status.
+func runMigrate(database *db.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down [steps] | status")
+	}
+	switch args[0] {
+	case "up":
+		n, err := database.MigrateUp()
+		log.Printf("applied %d migration(s)", n)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("migrate down: invalid step count %q", args[1])
+			}
+		}
+		n, err := database.MigrateDown(steps)
+		log.Printf("reverted %d migration(s)", n)
+		return err
+	case "status":
+		statuses, err := database.MigrationStatuses()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-40s %s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("migrate: unknown command %q", args[0])
+	}
+}
+
+// Handles the "set-role" command, which changes a user's role. It is how the
+// first admin is made.
+func runSetRole(database *db.DB, args []string) error {
+	if len(args) != 2 || !auth.ValidRole(args[1]) {
+		return errors.New("usage: set-role <email> user | moderator | admin")
+	}
+	user, err := database.GetUserByEmail(args[0])
+	if err != nil {
+		return err
+	}
+	if err := database.SetUserRole(user, args[1]); err != nil {
+		return err
+	}
+	log.Printf("set the role of %s to %s", user.Email, args[1])
+	return nil
+}
+
+// graphqlHandler serves queries and mutations sent as JSON over HTTP, like
+// relay.Handler does for a plain *graphql.Schema.
+type graphqlHandler struct {
+	schema resolvers.Schema
+}
+
+func (h *graphqlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response := h.schema.Exec(r.Context(), params.Query, params.OperationName, params.Variables)
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseJSON)
+}
+
+// statsHandler serves internal counters as JSON to admins. It must be wrapped
+// in auth.Middleware.
+type statsHandler struct {
+	resolver *resolvers.RootResolver
+}
+
+func (h *statsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, err := auth.Require(r.Context(), auth.PermissionViewStats); err != nil {
+		status := http.StatusUnauthorized
+		if _, ok := err.(*auth.PermissionError); ok {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	responseJSON, err := json.Marshal(map[string]interface{}{
+		"subscriptions": h.resolver.Events.Stats(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseJSON)
+}
+
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		os.Exit(2)
+	}
+
+	commands := map[string]func(*db.DB, []string) error{
+		"migrate":  runMigrate,
+		"set-role": runSetRole,
+	}
+	if len(cfg.Args) > 0 && commands[cfg.Args[0]] != nil {
+		database, err := db.Open(cfg)
+		if err != nil {
+			panic(err)
+		}
+		defer database.Close()
+		if err := commands[cfg.Args[0]](database, cfg.Args[1:]); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	mux := http.NewServeMux()
 
-	database, err := db.NewDB("./db.sqlite")
+	database, err := db.NewDB(cfg)
 
 	if err != nil {
 		panic(err)
 	}
 
-	rootResolver, err := resolvers.NewRoot(database)
+	if cfg.Database.Seed {
+		if err := database.Seed(); err != nil {
+			panic(err)
+		}
+	}
+
+	// ctx is cancelled when the server shuts down, stopping background work.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rootResolver, err := resolvers.NewRoot(ctx, database, cfg)
 
 	if err != nil {
 		panic(err)
 	}
 
-	schema := parseSchema("./schema.graphql", rootResolver)
-	wsHandler := graphqlws.NewHandlerFunc(
+	schema := resolvers.Schema{Schema: parseSchema(cfg.Server.SchemaPath, rootResolver)}
+	// Subscriptions run over websockets, everything else over plain HTTP.
+	wsHandler := ws.NewHandler(
 		schema,
-		&relay.Handler{
-			Schema: schema,
-		},
+		database,
+		loader.Middleware(database, &graphqlHandler{schema: schema}),
 	)
 
-	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
-		token := strings.ReplaceAll(r.Header.Get("Authorization"), "Bearer ", "")
-		ctx := context.WithValue(context.Background(), "token", token)
-		wsHandler.ServeHTTP(w, r.WithContext(ctx))
-	})
+	mux.Handle("/graphql", auth.Middleware(database, cfg.Server.TrustProxy, wsHandler))
+	if cfg.Server.StatsPath != "" {
+		mux.Handle(cfg.Server.StatsPath, auth.Middleware(database, cfg.Server.TrustProxy, &statsHandler{resolver: rootResolver}))
+	}
 
-	// necessary CORS options. Should not be used in production
-	// AllowedOrigins should be more specific than * and the
-	// AllowOriginFunc should not be present. This code is not
-	// written for production or for a CORS tutorial so this is fine
-	// for its purpose.
 	handler := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowCredentials: true,
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowCredentials: cfg.CORS.AllowCredentials,
 		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
 		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowOriginFunc:  func(origin string) bool { return true },
-		// Enable Debugging for testing, consider disabling in production
-		Debug: false,
+		Debug:            cfg.CORS.Debug,
 	}).Handler(mux)
 
 	s := &http.Server{
-		Addr:              addr,
+		Addr:              cfg.Server.Addr,
 		Handler:           handler,
-		ReadHeaderTimeout: readHeaderTimeout,
-		WriteTimeout:      writeTimeout,
-		IdleTimeout:       idleTimeout,
-		MaxHeaderBytes:    maxHeaderBytes,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
 	}
 
+	// Stop gracefully on SIGINT or SIGTERM.
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		log.Println("Shutting down")
+		cancel()
+		shutdownCtx, done := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer done()
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			log.Println("server.Shutdown:", err)
+		}
+	}()
+
 	// Begin listeing for requests.
 	log.Printf("Listening for requests on %s", s.Addr)
 
-	if err := s.ListenAndServe(); err != nil {
+	if err := s.ListenAndServe(); err != http.ErrServerClosed {
 		log.Println("server.ListenAndServe:", err)
+		return
 	}
+	<-stopped
 }
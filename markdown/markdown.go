@@ -0,0 +1,352 @@
+// Package markdown renders the Markdown users write in posts as HTML that is
+// safe to embed in a page.
+//
+// Only a subset is supported: paragraphs, emphasis, code spans and blocks,
+// block quotes, lists and links. Raw HTML is never passed through but shown as
+// text, and links may only point to http, https and mailto urls.
+package markdown
+
+import (
+	"net/url"
+	"strings"
+)
+
+// maxDepth bounds how deeply quotes and emphasis nest. Anything deeper is
+// rendered as plain text.
+const maxDepth = 8
+
+// linkRel is set on every link, as their targets are chosen by users.
+const linkRel = "nofollow noopener"
+
+// ToHTML renders src as HTML.
+func ToHTML(src string) string {
+	src = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\t", "    ").Replace(src)
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), 0)
+	return b.String()
+}
+
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			b.WriteString("<p>")
+			renderInline(b, strings.Join(paragraph, "\n"), 0, false)
+			b.WriteString("</p>\n")
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+			i++
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			i++
+			start := i
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+				i++
+			}
+			writeCode(b, lines[start:i])
+			i++ // closing fence
+		case len(paragraph) == 0 && strings.HasPrefix(line, "    "):
+			start := i
+			for i < len(lines) && (strings.HasPrefix(lines[i], "    ") || strings.TrimSpace(lines[i]) == "") {
+				i++
+			}
+			end := i
+			for end > start && strings.TrimSpace(lines[end-1]) == "" {
+				end--
+			}
+			code := make([]string, 0, end-start)
+			for _, l := range lines[start:end] {
+				code = append(code, strings.TrimPrefix(l, "    "))
+			}
+			writeCode(b, code)
+		case strings.HasPrefix(trimmed, ">") && depth < maxDepth:
+			flush()
+			var quoted []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				l := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(l, " "))
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted, depth+1)
+			b.WriteString("</blockquote>\n")
+		default:
+			if ordered, _, ok := listItem(line); ok {
+				flush()
+				i = renderList(b, lines, i, ordered)
+				continue
+			}
+			paragraph = append(paragraph, trimmed)
+			i++
+		}
+	}
+	flush()
+}
+
+// renderList renders the list starting at lines[i] and returns the index of
+// the first line after it. Items are rendered inline only.
+func renderList(b *strings.Builder, lines []string, i int, ordered bool) int {
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag + ">\n")
+	for i < len(lines) {
+		o, text, ok := listItem(lines[i])
+		if !ok || o != ordered {
+			break
+		}
+		item := []string{text}
+		i++
+		for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+			if _, _, ok := listItem(lines[i]); ok {
+				break
+			}
+			item = append(item, strings.TrimSpace(lines[i]))
+			i++
+		}
+		b.WriteString("<li>")
+		renderInline(b, strings.Join(item, "\n"), 0, false)
+		b.WriteString("</li>\n")
+
+		// Blank lines between items don't end the list.
+		next := i
+		for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+			next++
+		}
+		if next < len(lines) {
+			if o, _, ok := listItem(lines[next]); ok && o == ordered {
+				i = next
+			}
+		}
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// listItem reports whether line starts a list item, whether the list is
+// ordered and the item's text.
+func listItem(line string) (ordered bool, text string, ok bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 2 {
+		return false, "", false
+	}
+	switch trimmed[0] {
+	case '-', '*', '+':
+		if trimmed[1] == ' ' {
+			return false, strings.TrimSpace(trimmed[2:]), true
+		}
+		return false, "", false
+	}
+	digits := 0
+	for digits < len(trimmed) && digits < 9 && trimmed[digits] >= '0' && trimmed[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || digits+1 >= len(trimmed) {
+		return false, "", false
+	}
+	if (trimmed[digits] == '.' || trimmed[digits] == ')') && trimmed[digits+1] == ' ' {
+		return true, strings.TrimSpace(trimmed[digits+2:]), true
+	}
+	return false, "", false
+}
+
+func writeCode(b *strings.Builder, lines []string) {
+	b.WriteString("<pre><code>")
+	for _, line := range lines {
+		writeEscaped(b, line)
+		b.WriteByte('\n')
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+// renderInline renders emphasis, code spans and links within a block.
+// inLink is set within the text of a link, which can't contain other links.
+func renderInline(b *strings.Builder, text string, depth int, inLink bool) {
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && isPunct(text[i+1]):
+			writeEscaped(b, text[i+1:i+2])
+			i += 2
+			continue
+		case c == '`':
+			if n, ok := codeSpan(b, text, i); ok {
+				i += n
+				continue
+			}
+		case (c == '*' || c == '_') && depth < maxDepth:
+			if n, ok := emphasis(b, text, i, depth, inLink); ok {
+				i += n
+				continue
+			}
+		case c == '[' && !inLink:
+			if n, ok := link(b, text, i, depth); ok {
+				i += n
+				continue
+			}
+		case c == 'h' && !inLink && (i == 0 || !isWordChar(text[i-1])):
+			if n, ok := autolink(b, text, i); ok {
+				i += n
+				continue
+			}
+		}
+		writeEscaped(b, text[i:i+1])
+		i++
+	}
+}
+
+// codeSpan renders the code span starting at text[i], delimited by a run of
+// backticks, and returns its length.
+func codeSpan(b *strings.Builder, text string, i int) (int, bool) {
+	n := 0
+	for i+n < len(text) && text[i+n] == '`' {
+		n++
+	}
+	delim := text[i : i+n]
+	end := strings.Index(text[i+n:], delim)
+	if end < 0 {
+		return 0, false
+	}
+	b.WriteString("<code>")
+	writeEscaped(b, strings.TrimSpace(text[i+n:i+n+end]))
+	b.WriteString("</code>")
+	return n + end + n, true
+}
+
+// emphasis renders *em*, _em_, **strong** or __strong__ starting at text[i]
+// and returns its length.
+func emphasis(b *strings.Builder, text string, i, depth int, inLink bool) (int, bool) {
+	c := text[i]
+	// Underscores within words, as in snake_case, aren't emphasis.
+	if c == '_' && i > 0 && isWordChar(text[i-1]) {
+		return 0, false
+	}
+	delim, tag := text[i:i+1], "em"
+	if i+1 < len(text) && text[i+1] == c {
+		delim, tag = text[i:i+2], "strong"
+	}
+	start := i + len(delim)
+	if start >= len(text) || isSpace(text[start]) {
+		return 0, false
+	}
+	end := strings.Index(text[start:], delim)
+	if end <= 0 || isSpace(text[start+end-1]) {
+		return 0, false
+	}
+	after := start + end + len(delim)
+	if c == '_' && after < len(text) && isWordChar(text[after]) {
+		return 0, false
+	}
+	b.WriteString("<" + tag + ">")
+	renderInline(b, text[start:start+end], depth+1, inLink)
+	b.WriteString("</" + tag + ">")
+	return after - i, true
+}
+
+// link renders the [text](url) link starting at text[i] and returns its
+// length. Links to unsafe urls are left as they are.
+func link(b *strings.Builder, text string, i, depth int) (int, bool) {
+	label := strings.Index(text[i:], "](")
+	if label < 0 {
+		return 0, false
+	}
+	rest := text[i+label+2:]
+	end := strings.IndexByte(rest, ')')
+	if end < 0 {
+		return 0, false
+	}
+	href := strings.TrimSpace(rest[:end])
+	if !safeURL(href) {
+		return 0, false
+	}
+	writeLink(b, href)
+	renderInline(b, text[i+1:i+label], depth+1, true)
+	b.WriteString("</a>")
+	return label + 2 + end + 1, true
+}
+
+// autolink renders the bare http or https url starting at text[i] and returns
+// its length.
+func autolink(b *strings.Builder, text string, i int) (int, bool) {
+	rest := text[i:]
+	if !strings.HasPrefix(rest, "http://") && !strings.HasPrefix(rest, "https://") {
+		return 0, false
+	}
+	end := strings.IndexAny(rest, " \n<>\"")
+	if end < 0 {
+		end = len(rest)
+	}
+	// Punctuation ending a sentence isn't part of the url.
+	href := strings.TrimRight(rest[:end], ".,;:!?)'")
+	if !safeURL(href) {
+		return 0, false
+	}
+	writeLink(b, href)
+	writeEscaped(b, href)
+	b.WriteString("</a>")
+	return len(href), true
+}
+
+func writeLink(b *strings.Builder, href string) {
+	b.WriteString(`<a href="`)
+	writeEscaped(b, href)
+	b.WriteString(`" rel="` + linkRel + `">`)
+}
+
+// safeURL reports whether href is an absolute http, https or mailto url.
+func safeURL(href string) bool {
+	if href == "" || strings.ContainsAny(href, " \n") {
+		return false
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return u.Opaque != ""
+	}
+	return false
+}
+
+func writeEscaped(b *strings.Builder, s string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '&':
+			b.WriteString("&amp;")
+		case '<':
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '"':
+			b.WriteString("&#34;")
+		case '\'':
+			b.WriteString("&#39;")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n'
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,106 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"inline",
+			"**bold** and _em_ and `code` in snake_case_word",
+			"<p><strong>bold</strong> and <em>em</em> and <code>code</code> in snake_case_word</p>\n",
+		},
+		{
+			"links",
+			"[**docs**](https://example.com/a?b=1&c=2) and https://example.com.",
+			`<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener"><strong>docs</strong></a>` +
+				` and <a href="https://example.com" rel="nofollow noopener">https://example.com</a>.</p>` + "\n",
+		},
+		{
+			"mailto",
+			"[mail](mailto:a@example.com)",
+			`<p><a href="mailto:a@example.com" rel="nofollow noopener">mail</a></p>` + "\n",
+		},
+		{
+			"blocks",
+			"> quoted\n\n- one\n- two\n\n1. first\n\n    code",
+			"<blockquote>\n<p>quoted</p>\n</blockquote>\n<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n" +
+				"<ol>\n<li>first</li>\n</ol>\n<pre><code>code\n</code></pre>\n",
+		},
+	}
+	for _, test := range tests {
+		if got := ToHTML(test.src); got != test.want {
+			t.Errorf("%s: ToHTML(%q) =\n%q\nwant\n%q", test.name, test.src, got, test.want)
+		}
+	}
+}
+
+func TestToHTMLEscapesHTML(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"script",
+			"<script>alert(1)</script>",
+			"<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n",
+		},
+		{
+			"event handler attribute",
+			"<img src=x onerror=alert(1)>",
+			"<p>&lt;img src=x onerror=alert(1)&gt;</p>\n",
+		},
+		{
+			"script in a code block",
+			"```\n</code><script>alert(1)</script>\n```",
+			"<pre><code>&lt;/code&gt;&lt;script&gt;alert(1)&lt;/script&gt;\n</code></pre>\n",
+		},
+		{
+			"script in a code span",
+			"`</code><script>`",
+			"<p><code>&lt;/code&gt;&lt;script&gt;</code></p>\n",
+		},
+		{
+			"quote breaking out of a link",
+			`[x](https://example.com/"onmouseover="alert(1))`,
+			`<p><a href="https://example.com/&#34;onmouseover=&#34;alert(1" rel="nofollow noopener">x</a>)</p>` + "\n",
+		},
+		{
+			"quote breaking out of an autolink",
+			`https://example.com/"onmouseover=alert(1)`,
+			`<p><a href="https://example.com/" rel="nofollow noopener">https://example.com/</a>&#34;onmouseover=alert(1)</p>` + "\n",
+		},
+	}
+	for _, test := range tests {
+		if got := ToHTML(test.src); got != test.want {
+			t.Errorf("%s: ToHTML(%q) =\n%q\nwant\n%q", test.name, test.src, got, test.want)
+		}
+	}
+}
+
+func TestToHTMLRejectsUnsafeURLs(t *testing.T) {
+	for _, href := range []string{
+		"javascript:alert(1)",
+		"JavaScript:alert(1)",
+		" javascript:alert(1)",
+		"vbscript:msgbox(1)",
+		"data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==",
+		"data:image/svg+xml,<svg onload=alert(1)>",
+		"//example.com/protocol-relative",
+		"/relative/path",
+		"https:///no-host",
+		"mailto:",
+	} {
+		src := "[click](" + href + ")"
+		got := ToHTML(src)
+		if strings.Contains(got, "<a ") {
+			t.Errorf("ToHTML(%q) made a link: %q", src, got)
+		}
+	}
+	if got := ToHTML("javascript:alert(1)"); strings.Contains(got, "<a ") {
+		t.Errorf("bare javascript: url was linked: %q", got)
+	}
+}
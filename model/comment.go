@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Comment is a reply to a link, or to another comment on the same link.
+type Comment struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	AuthorID  uint      `json:"author_id"`
+	LinkID    uint      `json:"link_id"`
+	// ParentID is nil for top-level comments.
+	ParentID *uint `json:"parent_id"`
+	// Depth is 0 for top-level comments and one more than the parent's otherwise.
+	Depth int `json:"depth"`
+}
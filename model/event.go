@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// Event is a subscription event in the outbox the API instances sharing a
+// database poll. IDs increase in the order events were published.
+type Event struct {
+	ID    uint   `gorm:"primary_key" json:"id"`
+	Topic string `json:"topic"`
+	// Payload is the JSON encoded event.
+	Payload   string    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
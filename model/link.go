@@ -2,6 +2,14 @@ package model
 
 import "time"
 
+// Post types. Links point to a url; the others may have a body instead.
+const (
+	PostTypeLink = "link"
+	PostTypeAsk  = "ask"
+	PostTypeShow = "show"
+	PostTypeJob  = "job"
+)
+
 type Link struct {
 	ID          uint      `gorm:"primary_key" json:"id"`
 	CreatedAt   time.Time `json:"created_at"`
@@ -9,4 +17,17 @@ type Link struct {
 	Url         string    `json:"url"`
 	PosterID    uint      `json:"poster_id"`
 	Votes       []Vote    `json:"votes"`
+	// Type is one of the PostType constants.
+	Type string `json:"type"`
+	// Body is the Markdown text of the post, if any.
+	Body string `json:"body"`
+	// RankScore is the cached front page ranking, refreshed as votes come in
+	// and as the link ages.
+	RankScore float64    `json:"rank_score"`
+	RankedAt  *time.Time `json:"ranked_at"`
+	// UpdatedAt is when the link was last edited, if ever.
+	UpdatedAt *time.Time `json:"updated_at"`
+	// DeletedAt marks deleted links. They are kept so that their votes and
+	// comments still have a link to point to.
+	DeletedAt *time.Time `json:"deleted_at"`
 }
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// RefreshToken is a long-lived token that can be exchanged for a new access
+// token. Only a hash of the token is stored.
+type RefreshToken struct {
+	ID           uint       `gorm:"primary_key" json:"id"`
+	UserID       uint       `json:"user_id"`
+	TokenHash    string     `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+}
+
+// RevokedToken records the ID of an access token that was revoked before it expired.
+type RevokedToken struct {
+	JTI       string    `gorm:"primary_key;column:jti" json:"jti"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
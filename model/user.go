@@ -2,6 +2,13 @@ package model
 
 import "golang.org/x/crypto/bcrypt"
 
+// User roles. Each role has every permission of the roles before it.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type User struct {
 	ID             uint   `gorm:"primary_key" json:"id"`
 	Email          string `json:"email"`
@@ -9,6 +16,11 @@ type User struct {
 	Links          []Link `json:"links"`
 	Votes          []Vote `json:"votes"`
 	HashedPassword []byte `json:"-"`
+	// Role is one of the Role constants.
+	Role string `json:"role"`
+	// TokenGeneration is put in the access tokens issued to the user. Raising
+	// it invalidates every access token issued before.
+	TokenGeneration int `json:"-"`
 }
 
 // ComparePasswordHash takes a password hash and a plaintext password and returns true
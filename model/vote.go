@@ -1,7 +1,14 @@
 package model
 
+// Vote directions. A user has at most one vote per link.
+const (
+	Upvote   = 1
+	Downvote = -1
+)
+
 type Vote struct {
-	ID     uint
-	UserID uint
-	LinkID uint
+	ID        uint
+	UserID    uint
+	LinkID    uint
+	Direction int
 }
@@ -0,0 +1,72 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Message is an event as it travels over a Bus.
+type Message struct {
+	// ID is assigned by the EventLog when the message is published.
+	ID      uint
+	Topic   string
+	Payload []byte
+}
+
+// Bus carries events between the API instances sharing it, so that
+// subscribers connected to any instance see events published on all of them.
+type Bus interface {
+	// Publish records a message in the event log and sends it to every
+	// instance listening on the bus, including this one.
+	Publish(msg Message) error
+	// Listen starts calling handle with the messages published from now on,
+	// in order, until ctx is done. handle is never called concurrently.
+	Listen(ctx context.Context, handle func(Message)) error
+}
+
+// MemoryBus is a Bus within a single process. Messages are still recorded in
+// the event log, for replay.
+type MemoryBus struct {
+	Log *EventLog
+
+	mu       sync.Mutex
+	handlers map[int]func(Message)
+	nextID   int
+}
+
+// NewMemoryBus returns a bus that only reaches listeners in this process.
+func NewMemoryBus(eventLog *EventLog) *MemoryBus {
+	return &MemoryBus{Log: eventLog, handlers: map[int]func(Message){}}
+}
+
+// Publish calls every listener's handler before returning. Messages are
+// appended and handled under one lock, so listeners see them in ID order.
+func (b *MemoryBus) Publish(msg Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	msg, err := b.Log.Append(msg)
+	if err != nil {
+		return err
+	}
+	for _, handle := range b.handlers {
+		handle(msg)
+	}
+	return nil
+}
+
+// Listen registers handle until ctx is done.
+func (b *MemoryBus) Listen(ctx context.Context, handle func(Message)) error {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handle
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.handlers, id)
+		b.mu.Unlock()
+	}()
+	return nil
+}
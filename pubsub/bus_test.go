@@ -0,0 +1,183 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package pubsub
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leggettc18/hackernews-clone-api/config"
+	"github.com/leggettc18/hackernews-clone-api/db"
+)
+
+const (
+	testPollInterval = 10 * time.Millisecond
+	testTimeout      = 2 * time.Second
+)
+
+// testDBPath returns the path of a database file in a temporary directory,
+// removed when the test ends.
+func testDBPath(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "hackernews-clone-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "db.sqlite")
+}
+
+// openTestDB opens the database at path, migrating it if needed. Opening the
+// same path twice stands in for two API instances sharing a database.
+func openTestDB(t *testing.T, path string) *db.DB {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Database.Path = path
+	cfg.Auth.JWTSecret = "0123456789abcdef0123"
+	database, err := db.NewDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// listen starts listening on bus until the test ends and returns the channel
+// the messages are passed on to.
+func listen(t *testing.T, bus Bus) <-chan Message {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	received := make(chan Message, 100)
+	if err := bus.Listen(ctx, func(msg Message) { received <- msg }); err != nil {
+		t.Fatal(err)
+	}
+	return received
+}
+
+func receive(t *testing.T, received <-chan Message) Message {
+	t.Helper()
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(testTimeout):
+		t.Fatal("no message received")
+		return Message{}
+	}
+}
+
+func expectNothing(t *testing.T, received <-chan Message) {
+	t.Helper()
+	select {
+	case msg := <-received:
+		t.Errorf("unexpected message %d %s %s", msg.ID, msg.Topic, msg.Payload)
+	case <-time.After(5 * testPollInterval):
+	}
+}
+
+func TestOutboxBusAcrossInstances(t *testing.T) {
+	path := testDBPath(t)
+	busA := NewOutboxBus(NewEventLog(openTestDB(t, path), time.Hour), testPollInterval)
+	busB := NewOutboxBus(NewEventLog(openTestDB(t, path), time.Hour), testPollInterval)
+	receivedA, receivedB := listen(t, busA), listen(t, busB)
+
+	if err := busA.Publish(Message{Topic: "t", Payload: []byte("from A")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := busB.Publish(Message{Topic: "t", Payload: []byte("from B")}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, received := range map[string]<-chan Message{"A": receivedA, "B": receivedB} {
+		first, second := receive(t, received), receive(t, received)
+		if string(first.Payload) != "from A" || string(second.Payload) != "from B" {
+			t.Errorf("instance %s got %q then %q, want both messages in order", name, first.Payload, second.Payload)
+		}
+		if first.ID == 0 || second.ID <= first.ID {
+			t.Errorf("instance %s got IDs %d then %d, want increasing IDs", name, first.ID, second.ID)
+		}
+	}
+}
+
+func TestOutboxBusRestartSkipsOldMessages(t *testing.T) {
+	path := testDBPath(t)
+	busA := NewOutboxBus(NewEventLog(openTestDB(t, path), time.Hour), testPollInterval)
+	if err := busA.Publish(Message{Topic: "t", Payload: []byte("before")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// B starts after the first message, as if it had been restarted.
+	logB := NewEventLog(openTestDB(t, path), time.Hour)
+	receivedB := listen(t, NewOutboxBus(logB, testPollInterval))
+	expectNothing(t, receivedB)
+
+	if err := busA.Publish(Message{Topic: "t", Payload: []byte("after")}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, receivedB); string(msg.Payload) != "after" {
+		t.Errorf("got %q, want the message published after the restart", msg.Payload)
+	}
+	expectNothing(t, receivedB)
+
+	// Subscribers catch up on the earlier message from the log instead.
+	missed, err := logB.Since("t", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missed) != 2 || string(missed[0].Payload) != "before" {
+		t.Errorf("log has %d messages, want 2 starting with the one before the restart", len(missed))
+	}
+}
+
+func TestMemoryBus(t *testing.T) {
+	eventLog := NewEventLog(openTestDB(t, testDBPath(t)), time.Hour)
+	bus := NewMemoryBus(eventLog)
+	ctx, cancel := context.WithCancel(context.Background())
+	var received []Message
+	if err := bus.Listen(ctx, func(msg Message) { received = append(received, msg) }); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, payload := range []string{"one", "two"} {
+		if err := bus.Publish(Message{Topic: "t", Payload: []byte(payload)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Handlers run before Publish returns.
+	if len(received) != 2 || string(received[0].Payload) != "one" || received[1].ID <= received[0].ID {
+		t.Fatalf("got %v, want both messages in order with increasing IDs", received)
+	}
+	logged, err := eventLog.Since("t", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) != 2 || logged[1].ID != received[1].ID {
+		t.Errorf("log has %v, want the published messages", logged)
+	}
+
+	cancel()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		bus.mu.Lock()
+		listening := len(bus.handlers)
+		bus.mu.Unlock()
+		if listening == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("handler still registered after its context was cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := bus.Publish(Message{Topic: "t", Payload: []byte("three")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 {
+		t.Errorf("handler called after its context was cancelled")
+	}
+}
@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/leggettc18/hackernews-clone-api/db"
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+var (
+	// ErrEventsExpired is returned when asked for events some of which were
+	// already deleted from the log.
+	ErrEventsExpired = errors.New("events are no longer retained")
+	// ErrUnknownEvent is returned when asked for the events after one that
+	// hasn't been published.
+	ErrUnknownEvent = errors.New("no such event")
+)
+
+// EventLog keeps published messages in the events table for a while, so that
+// subscribers that reconnect can catch up on what they missed. Messages get
+// their IDs from the table, in the order they were published.
+type EventLog struct {
+	DB *db.DB
+	// Retention is how long messages are kept.
+	Retention time.Duration
+}
+
+// NewEventLog returns a log over the events table of database.
+func NewEventLog(database *db.DB, retention time.Duration) *EventLog {
+	return &EventLog{DB: database, Retention: retention}
+}
+
+// Append stores msg and returns it with its ID set.
+func (l *EventLog) Append(msg Message) (Message, error) {
+	event := model.Event{Topic: msg.Topic, Payload: string(msg.Payload), CreatedAt: time.Now()}
+	if err := l.DB.CreateEvent(&event); err != nil {
+		return msg, err
+	}
+	msg.ID = event.ID
+	return msg, nil
+}
+
+// Since returns up to limit messages on topic published after the one with
+// the given ID, oldest first. It returns ErrUnknownEvent if there is no such
+// message yet, and ErrEventsExpired if messages after it were already deleted.
+func (l *EventLog) Since(topic string, id uint, limit int) ([]Message, error) {
+	latest, err := l.DB.LatestEventId()
+	if err != nil {
+		return nil, err
+	}
+	if id > latest {
+		return nil, ErrUnknownEvent
+	}
+	oldest, err := l.DB.OldestEventId()
+	if err != nil {
+		return nil, err
+	}
+	if oldest == 0 {
+		// Every message has been deleted, up to the latest.
+		oldest = latest + 1
+	}
+	if oldest > id+1 {
+		return nil, ErrEventsExpired
+	}
+	events, err := l.DB.GetTopicEventsAfter(topic, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	return messages(events), nil
+}
+
+// Run deletes expired messages every tenth of the retention until ctx is done.
+func (l *EventLog) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.Retention / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := l.DB.DeleteEventsBefore(time.Now().Add(-l.Retention)); err != nil {
+			log.Println("pubsub: unable to delete old events:", err)
+		}
+	}
+}
+
+func messages(events []*model.Event) []Message {
+	msgs := make([]Message, 0, len(events))
+	for _, event := range events {
+		msgs = append(msgs, Message{ID: event.ID, Topic: event.Topic, Payload: []byte(event.Payload)})
+	}
+	return msgs
+}
@@ -0,0 +1,79 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func appendMessages(t *testing.T, eventLog *EventLog, topic string, n int) []Message {
+	t.Helper()
+	var msgs []Message
+	for i := 0; i < n; i++ {
+		msg, err := eventLog.Append(Message{Topic: topic, Payload: []byte("{}")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestEventLogSince(t *testing.T) {
+	eventLog := NewEventLog(openTestDB(t, testDBPath(t)), time.Hour)
+	if msgs, err := eventLog.Since("a", 0, 10); err != nil || len(msgs) != 0 {
+		t.Errorf("empty log: got %v, %v", msgs, err)
+	}
+	appendMessages(t, eventLog, "a", 2)
+	b := appendMessages(t, eventLog, "b", 1)
+	a := appendMessages(t, eventLog, "a", 2)
+
+	msgs, err := eventLog.Since("a", b[0].ID-1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].ID != a[0].ID || msgs[1].ID != a[1].ID {
+		t.Errorf("got %v, want the messages on the topic after %d", msgs, b[0].ID-1)
+	}
+	if msgs, err := eventLog.Since("a", a[1].ID, 10); err != nil || len(msgs) != 0 {
+		t.Errorf("after the latest: got %v, %v", msgs, err)
+	}
+	if _, err := eventLog.Since("a", a[1].ID+1, 10); err != ErrUnknownEvent {
+		t.Errorf("after an unpublished message: got %v, want ErrUnknownEvent", err)
+	}
+}
+
+func TestEventLogSinceExpired(t *testing.T) {
+	eventLog := NewEventLog(openTestDB(t, testDBPath(t)), time.Hour)
+	msgs := appendMessages(t, eventLog, "a", 3)
+	latest := msgs[2].ID
+
+	if err := eventLog.DB.DeleteEventsBefore(time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// With every message deleted, only the latest ID can still be resumed from.
+	for _, id := range []uint{0, msgs[0].ID, msgs[1].ID} {
+		if _, err := eventLog.Since("a", id, 10); err != ErrEventsExpired {
+			t.Errorf("after %d once all were deleted: got %v, want ErrEventsExpired", id, err)
+		}
+	}
+	if got, err := eventLog.Since("a", latest, 10); err != nil || len(got) != 0 {
+		t.Errorf("after the latest once all were deleted: got %v, %v", got, err)
+	}
+	if _, err := eventLog.Since("a", latest+1, 10); err != ErrUnknownEvent {
+		t.Errorf("after an unpublished message: got %v, want ErrUnknownEvent", err)
+	}
+
+	next := appendMessages(t, eventLog, "a", 1)
+	if next[0].ID <= latest {
+		t.Errorf("new message got ID %d, want IDs never reused after %d", next[0].ID, latest)
+	}
+	if got, err := eventLog.Since("a", latest, 10); err != nil || len(got) != 1 {
+		t.Errorf("after the latest deleted message: got %v, %v", got, err)
+	}
+	if _, err := eventLog.Since("a", latest-1, 10); err != ErrEventsExpired {
+		t.Errorf("before a deleted message: got %v, want ErrEventsExpired", err)
+	}
+}
@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// outboxBatchSize is the most events read from the outbox per query.
+const outboxBatchSize = 500
+
+// OutboxBus is a Bus for instances sharing a database. Publishing appends the
+// message to the event log, and every instance polls the log for messages it
+// hasn't seen.
+type OutboxBus struct {
+	Log *EventLog
+	// PollInterval is how often the log is checked for new messages.
+	PollInterval time.Duration
+}
+
+// NewOutboxBus returns a bus over the given event log.
+func NewOutboxBus(eventLog *EventLog, pollInterval time.Duration) *OutboxBus {
+	return &OutboxBus{Log: eventLog, PollInterval: pollInterval}
+}
+
+// Publish stores the message in the log. Listeners, including this
+// instance's, receive it on their next poll.
+func (b *OutboxBus) Publish(msg Message) error {
+	_, err := b.Log.Append(msg)
+	return err
+}
+
+// Listen starts polling for messages published after it was called.
+func (b *OutboxBus) Listen(ctx context.Context, handle func(Message)) error {
+	cursor, err := b.Log.DB.LatestEventId()
+	if err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(b.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			cursor = b.poll(cursor, handle)
+		}
+	}()
+	return nil
+}
+
+// poll hands every message after cursor to handle and returns the new cursor.
+func (b *OutboxBus) poll(cursor uint, handle func(Message)) uint {
+	for {
+		events, err := b.Log.DB.GetEventsAfter(cursor, outboxBatchSize)
+		if err != nil {
+			log.Println("pubsub: unable to poll events:", err)
+			return cursor
+		}
+		for _, msg := range messages(events) {
+			handle(msg)
+			cursor = msg.ID
+		}
+		if len(events) < outboxBatchSize {
+			return cursor
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Package pubsub delivers events published on named topics to their
+// subscribers, giving every subscriber its own bounded buffer so that a slow
+// subscriber can't hold up publishers or the other subscribers.
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Policy decides what happens when an event is published to a subscriber
+// whose buffer is full.
+type Policy string
+
+const (
+	// DropOldest discards the oldest buffered event to make room.
+	DropOldest Policy = "drop-oldest"
+	// Disconnect ends the subscription, closing its channel.
+	Disconnect Policy = "disconnect"
+)
+
+// ParsePolicy returns the policy with the given name.
+func ParsePolicy(name string) (Policy, error) {
+	switch policy := Policy(name); policy {
+	case DropOldest, Disconnect:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown overflow policy %q", name)
+}
+
+// Filter reports whether a subscriber wants an event. It is called while
+// publishing, so it must be quick. A nil Filter accepts every event.
+type Filter func(event interface{}) bool
+
+// Hub routes published events to the subscribers of their topic. It is safe
+// for concurrent use.
+type Hub struct {
+	bufferSize int
+	policy     Policy
+
+	mu     sync.RWMutex
+	topics map[string]*topic
+}
+
+type topic struct {
+	subscribers map[*Subscription]struct{}
+	stats       TopicStats
+}
+
+// TopicStats counts what happened to the events of a topic.
+type TopicStats struct {
+	Subscribers int    `json:"subscribers"`
+	Published   uint64 `json:"published"`
+	// Delivered counts events put in a subscriber's buffer, once per
+	// subscriber. Events a subscriber's filter rejects aren't counted.
+	Delivered uint64 `json:"delivered"`
+	// Dropped counts events discarded from full buffers.
+	Dropped uint64 `json:"dropped"`
+	// Disconnected counts subscriptions ended because their buffer was full.
+	Disconnected uint64 `json:"disconnected"`
+}
+
+// NewHub returns a hub that buffers up to bufferSize events per subscriber
+// and applies policy when a buffer overflows.
+func NewHub(bufferSize int, policy Policy) *Hub {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &Hub{bufferSize: bufferSize, policy: policy, topics: map[string]*topic{}}
+}
+
+// Subscription receives the events published to a topic.
+type Subscription struct {
+	hub    *Hub
+	topic  string
+	filter Filter
+	events chan interface{}
+
+	// mu serializes sends with closing the channel.
+	mu     sync.Mutex
+	closed bool
+}
+
+// Subscribe returns a subscription to the events on topic that filter
+// accepts. It is closed when ctx is done, or earlier by Close or the
+// Disconnect policy.
+func (h *Hub) Subscribe(ctx context.Context, topicName string, filter Filter) *Subscription {
+	s := &Subscription{hub: h, topic: topicName, filter: filter, events: make(chan interface{}, h.bufferSize)}
+
+	h.mu.Lock()
+	t := h.topics[topicName]
+	if t == nil {
+		t = &topic{subscribers: map[*Subscription]struct{}{}}
+		h.topics[topicName] = t
+	}
+	t.subscribers[s] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+	return s
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// subscription ends.
+func (s *Subscription) Events() <-chan interface{} {
+	return s.events
+}
+
+// Close ends the subscription. Events still buffered can be drained from the
+// channel.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	if t := s.hub.topics[s.topic]; t != nil {
+		delete(t.subscribers, s)
+	}
+	s.hub.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+}
+
+// Publish delivers event to every current subscriber of topic whose filter
+// accepts it. It never blocks: a full buffer is handled by the hub's overflow
+// policy.
+func (h *Hub) Publish(topicName string, event interface{}) {
+	h.mu.Lock()
+	t := h.topics[topicName]
+	if t == nil {
+		t = &topic{subscribers: map[*Subscription]struct{}{}}
+		h.topics[topicName] = t
+	}
+	t.stats.Published++
+	var overflowed []*Subscription
+	for s := range t.subscribers {
+		if s.filter != nil && !s.filter(event) {
+			continue
+		}
+		delivered, dropped := s.offer(event, h.policy)
+		switch {
+		case delivered:
+			t.stats.Delivered++
+		case h.policy == Disconnect:
+			t.stats.Disconnected++
+			overflowed = append(overflowed, s)
+		}
+		if dropped {
+			t.stats.Dropped++
+		}
+	}
+	h.mu.Unlock()
+
+	for _, s := range overflowed {
+		s.Close()
+	}
+}
+
+// offer buffers event, making room by dropping the oldest event if policy
+// allows. It reports whether event was buffered and whether an older event
+// was dropped for it.
+func (s *Subscription) offer(event interface{}, policy Policy) (delivered, dropped bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false, false
+	}
+	for {
+		select {
+		case s.events <- event:
+			return true, dropped
+		default:
+		}
+		if policy != DropOldest {
+			return false, false
+		}
+		// Only publishers fill the buffer and they hold the hub's lock, so
+		// after taking one event out the retry succeeds.
+		select {
+		case <-s.events:
+			dropped = true
+		default:
+		}
+	}
+}
+
+// Stats returns the counters of every topic that has been published or
+// subscribed to.
+func (h *Hub) Stats() map[string]TopicStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	stats := make(map[string]TopicStats, len(h.topics))
+	for name, t := range h.topics {
+		topicStats := t.stats
+		topicStats.Subscribers = len(t.subscribers)
+		stats[name] = topicStats
+	}
+	return stats
+}
@@ -0,0 +1,122 @@
+package pubsub
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// drain returns the events buffered on sub without waiting for more, and
+// whether the channel was closed.
+func drain(sub *Subscription) (events []interface{}, closed bool) {
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return events, true
+			}
+			events = append(events, event)
+		default:
+			return events, false
+		}
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	hub := NewHub(2, DropOldest)
+	sub := hub.Subscribe(context.Background(), "t", nil)
+	for i := 1; i <= 5; i++ {
+		hub.Publish("t", i)
+	}
+
+	events, closed := drain(sub)
+	if closed {
+		t.Error("subscription closed, want it kept open")
+	}
+	if want := []interface{}{4, 5}; !reflect.DeepEqual(events, want) {
+		t.Errorf("got events %v, want %v", events, want)
+	}
+	want := TopicStats{Subscribers: 1, Published: 5, Delivered: 5, Dropped: 3}
+	if stats := hub.Stats()["t"]; stats != want {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	hub := NewHub(2, Disconnect)
+	slow := hub.Subscribe(context.Background(), "t", nil)
+	fast := hub.Subscribe(context.Background(), "t", nil)
+	for i := 1; i <= 3; i++ {
+		hub.Publish("t", i)
+		if i == 2 {
+			drain(fast)
+		}
+	}
+
+	events, closed := drain(slow)
+	if !closed {
+		t.Error("overflowing subscription still open")
+	}
+	if want := []interface{}{1, 2}; !reflect.DeepEqual(events, want) {
+		t.Errorf("overflowing subscription got %v, want the events buffered before: %v", events, want)
+	}
+	if events, closed := drain(fast); closed || !reflect.DeepEqual(events, []interface{}{3}) {
+		t.Errorf("other subscription got %v (closed %v), want [3] and open", events, closed)
+	}
+	want := TopicStats{Subscribers: 1, Published: 3, Delivered: 5, Disconnected: 1}
+	if stats := hub.Stats()["t"]; stats != want {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	hub := NewHub(10, DropOldest)
+	even := func(event interface{}) bool { return event.(int)%2 == 0 }
+	sub := hub.Subscribe(context.Background(), "t", even)
+	hub.Subscribe(context.Background(), "other", nil)
+	for i := 1; i <= 4; i++ {
+		hub.Publish("t", i)
+	}
+	hub.Publish("other", 6)
+
+	if events, _ := drain(sub); !reflect.DeepEqual(events, []interface{}{2, 4}) {
+		t.Errorf("got events %v, want [2 4]", events)
+	}
+	if stats := hub.Stats()["t"]; stats.Delivered != 2 {
+		t.Errorf("delivered %d events, want 2", stats.Delivered)
+	}
+}
+
+func TestSubscriptionEndsWithContext(t *testing.T) {
+	hub := NewHub(1, DropOldest)
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := hub.Subscribe(ctx, "t", nil)
+	cancel()
+
+	select {
+	case _, ok := <-sub.Events():
+		if ok {
+			t.Fatal("got an event, want the channel closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription still open after its context was cancelled")
+	}
+	if stats := hub.Stats()["t"]; stats.Subscribers != 0 {
+		t.Errorf("%d subscribers left, want 0", stats.Subscribers)
+	}
+	// Publishing to a closed subscription must not panic.
+	hub.Publish("t", 1)
+	sub.Close()
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, name := range []string{"drop-oldest", "disconnect"} {
+		if policy, err := ParsePolicy(name); err != nil || string(policy) != name {
+			t.Errorf("ParsePolicy(%q) = %q, %v", name, policy, err)
+		}
+	}
+	if _, err := ParsePolicy("block"); err == nil {
+		t.Error("ParsePolicy accepted an unknown policy")
+	}
+}
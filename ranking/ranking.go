@@ -0,0 +1,104 @@
+package ranking
+
+import (
+	"context"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/leggettc18/hackernews-clone-api/config"
+	"github.com/leggettc18/hackernews-clone-api/db"
+	"github.com/leggettc18/hackernews-clone-api/linkurl"
+)
+
+// controversyThreshold is the number of comments above which a link whose
+// comments outnumber its votes is considered a flamewar.
+const controversyThreshold = 40
+
+// Score computes the Hacker News ranking of a link with the given number of
+// points at the given age: (points - 1) / (hours + 2)^gravity, scaled by penalty.
+// The poster's own implicit point is not counted, and links without any
+// other points rank 0, as scaling a negative rank down would raise it.
+func Score(points int, age time.Duration, gravity, penalty float64) float64 {
+	hours := math.Max(age.Hours(), 0)
+	return math.Max(float64(points-1), 0) / math.Pow(hours+2, gravity) * penalty
+}
+
+// Ranker computes link rankings and caches them on the links.
+type Ranker struct {
+	DB     *db.DB
+	Config config.Ranking
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	penalizedDomains map[string]bool
+}
+
+func NewRanker(db *db.DB, cfg config.Ranking) *Ranker {
+	domains := map[string]bool{}
+	for _, domain := range cfg.PenalizedDomains {
+		domains[strings.ToLower(domain)] = true
+	}
+	return &Ranker{
+		DB:               db,
+		Config:           cfg,
+		Now:              time.Now,
+		penalizedDomains: domains,
+	}
+}
+
+// Run refreshes the rankings of recent links every refresh interval until ctx is done.
+func (r *Ranker) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.RefreshAll(); err != nil {
+			log.Println("ranking: refresh failed:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RefreshAll recomputes the rank of every link inside the ranking window.
+func (r *Ranker) RefreshAll() error {
+	now := r.Now()
+	inputs, err := r.DB.GetRankingInputs(now.Add(-r.Config.Window))
+	if err != nil {
+		return err
+	}
+	ranks := make(map[uint]float64, len(inputs))
+	for _, input := range inputs {
+		ranks[input.ID] = r.rank(input, now)
+	}
+	return r.DB.UpdateLinkRanks(ranks)
+}
+
+// RefreshLink recomputes the rank of a single link, e.g. right after a vote.
+func (r *Ranker) RefreshLink(linkID uint) error {
+	input, err := r.DB.GetRankingInput(linkID)
+	if err != nil {
+		return err
+	}
+	return r.DB.UpdateLinkRanks(map[uint]float64{linkID: r.rank(*input, r.Now())})
+}
+
+func (r *Ranker) rank(input db.RankingInput, now time.Time) float64 {
+	return Score(input.Score+1, now.Sub(input.CreatedAt), r.Config.Gravity, r.penalty(input))
+}
+
+// penalty returns the factor a link's rank is scaled by.
+func (r *Ranker) penalty(input db.RankingInput) float64 {
+	penalty := 1.0
+	if r.penalizedDomains[linkurl.Domain(input.Url)] {
+		penalty *= r.Config.DomainPenalty
+	}
+	if input.CommentCount > controversyThreshold && input.CommentCount > input.Score {
+		penalty *= r.Config.ControversyPenalty
+	}
+	return penalty
+}
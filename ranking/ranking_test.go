@@ -0,0 +1,86 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/leggettc18/hackernews-clone-api/config"
+	"github.com/leggettc18/hackernews-clone-api/db"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		points  int
+		age     time.Duration
+		gravity float64
+		penalty float64
+		want    float64
+	}{
+		{"new link", 11, 0, 1.8, 1, 10 / math.Pow(2, 1.8)},
+		{"ten hours old", 11, 10 * time.Hour, 1.8, 1, 10 / math.Pow(12, 1.8)},
+		{"gravity 1", 11, 10 * time.Hour, 1, 1, 10.0 / 12},
+		{"future creation time", 11, -time.Hour, 1.8, 1, 10 / math.Pow(2, 1.8)},
+		{"penalized", 11, 10 * time.Hour, 1.8, 0.25, 10 / math.Pow(12, 1.8) * 0.25},
+		{"only the poster's point", 1, 0, 1.8, 1, 0},
+		{"downvoted", -5, 0, 1.8, 1, 0},
+		{"downvoted and penalized", -5, 0, 1.8, 0.25, 0},
+	}
+	for _, test := range tests {
+		got := Score(test.points, test.age, test.gravity, test.penalty)
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: Score(%d, %v, %v, %v) = %v, want %v", test.name, test.points, test.age, test.gravity, test.penalty, got, test.want)
+		}
+	}
+}
+
+func TestScoreDecays(t *testing.T) {
+	previous := math.Inf(1)
+	for hours := 0; hours <= 48; hours += 6 {
+		score := Score(20, time.Duration(hours)*time.Hour, 1.8, 1)
+		if score >= previous {
+			t.Errorf("score at %d hours is %v, not below %v", hours, score, previous)
+		}
+		previous = score
+	}
+}
+
+func TestPenalty(t *testing.T) {
+	r := NewRanker(nil, config.Ranking{
+		PenalizedDomains:   []string{"Example.com"},
+		DomainPenalty:      0.25,
+		ControversyPenalty: 0.5,
+	})
+	tests := []struct {
+		name  string
+		input db.RankingInput
+		want  float64
+	}{
+		{"no penalty", db.RankingInput{Url: "https://other.com/a", Score: 10, CommentCount: 5}, 1},
+		{"comments outnumbered by votes", db.RankingInput{Url: "https://other.com/a", Score: 100, CommentCount: 50}, 1},
+		{"penalized domain", db.RankingInput{Url: "https://www.example.com/a"}, 0.25},
+		{"flamewar", db.RankingInput{Url: "https://other.com/a", Score: 10, CommentCount: 41}, 0.5},
+		{"few comments", db.RankingInput{Url: "https://other.com/a", Score: 0, CommentCount: 40}, 1},
+		{"both", db.RankingInput{Url: "https://example.com/a", Score: 10, CommentCount: 41}, 0.125},
+	}
+	for _, test := range tests {
+		if got := r.penalty(test.input); got != test.want {
+			t.Errorf("%s: penalty = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPenaltiesNeverRaiseRank(t *testing.T) {
+	cfg := config.Ranking{Gravity: 1.8, PenalizedDomains: []string{"example.com"}, DomainPenalty: 0.25, ControversyPenalty: 0.5}
+	r := NewRanker(nil, cfg)
+	now := time.Now()
+	for _, score := range []int{-50, -1, 0, 1, 50} {
+		plain := db.RankingInput{CreatedAt: now.Add(-time.Hour), Url: "https://other.com", Score: score, CommentCount: 100}
+		penalized := plain
+		penalized.Url = "https://example.com"
+		if r.rank(penalized, now) > r.rank(plain, now) {
+			t.Errorf("score %d: penalized rank %v above %v", score, r.rank(penalized, now), r.rank(plain, now))
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package resolvers
 
 import (
-	"github.com/dgrijalva/jwt-go"
 	"github.com/leggettc18/hackernews-clone-api/db"
 	"github.com/leggettc18/hackernews-clone-api/model"
 )
@@ -12,25 +11,38 @@ type AuthResolver struct {
 }
 
 type AuthPayload struct {
-	Token *string
-	User  *model.User
+	Token        *string
+	RefreshToken *string
+	User         *model.User
 }
 
-func GenerateToken(user *model.User) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"ID": user.ID,
-	})
-	tokenString, errToken := token.SignedString([]byte("verysecret"))
+// newAuthResolver starts a new session for the user, issuing an access token
+// and a refresh token.
+func newAuthResolver(db *db.DB, user *model.User) (*AuthResolver, error) {
+	token, errToken := db.GenerateToken(user)
 	if errToken != nil {
-		return "", errToken
+		return nil, errToken
 	}
-	return tokenString, nil
+	refreshToken, errRefresh := db.CreateRefreshToken(user.ID)
+	if errRefresh != nil {
+		return nil, errRefresh
+	}
+	payload := AuthPayload{
+		Token:        &token,
+		RefreshToken: &refreshToken,
+		User:         user,
+	}
+	return &AuthResolver{db, payload}, nil
 }
 
 func (r *AuthResolver) Token() *string {
 	return r.AuthPayload.Token
 }
 
+func (r *AuthResolver) RefreshToken() *string {
+	return r.AuthPayload.RefreshToken
+}
+
 func (r *AuthResolver) User() *UserResolver {
-	return &UserResolver{r.DB, *r.AuthPayload.User}
+	return &UserResolver{DB: r.DB, User: *r.AuthPayload.User, self: true}
 }
@@ -0,0 +1,103 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/leggettc18/hackernews-clone-api/db"
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+type CommentResolver struct {
+	DB      *db.DB
+	Comment model.Comment
+	// thread holds the rest of the link's comments when they were loaded
+	// together, so children don't need a query per comment.
+	thread *commentThread
+}
+
+// commentThread indexes the comments of one link by their parent.
+type commentThread struct {
+	children map[uint][]*model.Comment
+}
+
+func newCommentThread(comments []*model.Comment) *commentThread {
+	thread := &commentThread{children: map[uint][]*model.Comment{}}
+	for _, comment := range comments {
+		var parentID uint
+		if comment.ParentID != nil {
+			parentID = *comment.ParentID
+		}
+		thread.children[parentID] = append(thread.children[parentID], comment)
+	}
+	return thread
+}
+
+// resolvers returns the resolvers for the replies to parentID, or for the
+// top-level comments if parentID is 0.
+func (t *commentThread) resolvers(db *db.DB, parentID uint) []*CommentResolver {
+	resolvers := []*CommentResolver{}
+	for _, comment := range t.children[parentID] {
+		resolvers = append(resolvers, &CommentResolver{db, *comment, t})
+	}
+	return resolvers
+}
+
+func (r *CommentResolver) ID() graphql.ID {
+	return graphql.ID(fmt.Sprint(r.Comment.ID))
+}
+
+func (r *CommentResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.Comment.CreatedAt}
+}
+
+func (r *CommentResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.Comment.UpdatedAt}
+}
+
+func (r *CommentResolver) Body() string {
+	return r.Comment.Body
+}
+
+func (r *CommentResolver) Depth() int32 {
+	return int32(r.Comment.Depth)
+}
+
+func (r *CommentResolver) Author(ctx context.Context) (*UserResolver, error) {
+	user, err := loadUser(ctx, r.DB, r.Comment.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	return &UserResolver{DB: r.DB, User: *user}, nil
+}
+
+func (r *CommentResolver) Link(ctx context.Context) (*LinkResolver, error) {
+	link, err := loadLink(ctx, r.DB, r.Comment.LinkID)
+	if err != nil {
+		return nil, err
+	}
+	return &LinkResolver{r.DB, *link}, nil
+}
+
+func (r *CommentResolver) Parent() (*CommentResolver, error) {
+	if r.Comment.ParentID == nil {
+		return nil, nil
+	}
+	parent, err := r.DB.GetCommentById(*r.Comment.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	return &CommentResolver{r.DB, *parent, r.thread}, nil
+}
+
+// Children returns the direct replies to the comment, oldest first.
+func (r *CommentResolver) Children() ([]*CommentResolver, error) {
+	if r.thread == nil {
+		comments, err := r.DB.GetCommentsByLinkId(r.Comment.LinkID)
+		if err != nil {
+			return nil, err
+		}
+		r.thread = newCommentThread(comments)
+	}
+	return r.thread.resolvers(r.DB, r.Comment.ID), nil
+}
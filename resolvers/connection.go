@@ -0,0 +1,247 @@
+package resolvers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/leggettc18/hackernews-clone-api/db"
+	apperrors "github.com/leggettc18/hackernews-clone-api/errors"
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ConnectionArgs are the standard Relay pagination arguments.
+type ConnectionArgs struct {
+	First  *int32
+	After  *graphql.ID
+	Last   *int32
+	Before *graphql.ID
+}
+
+// encodeCursor returns an opaque cursor pointing at the node of the given
+// kind and ID. Cursors stay valid as long as the node exists.
+func encodeCursor(kind string, id uint) graphql.ID {
+	return graphql.ID(base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", kind, id))))
+}
+
+// decodeCursor returns the ID of the node a cursor of the given kind points at.
+func decodeCursor(kind string, cursor graphql.ID) (uint, error) {
+	bstr, err := base64.RawURLEncoding.DecodeString(string(cursor))
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	parts := strings.SplitN(string(bstr), ":", 2)
+	if len(parts) != 2 || parts[0] != kind {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	id, err := getUintFromGraphqlId(graphql.ID(parts[1]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return id, nil
+}
+
+// keysetPage converts the pagination arguments into a page over nodes of the given kind.
+func (args ConnectionArgs) keysetPage(kind string, descending bool) (db.KeysetPage, error) {
+	var (
+		page = db.KeysetPage{Descending: descending}
+		errs apperrors.Errors
+	)
+	if args.First != nil && args.Last != nil {
+		errs = append(errs, apperrors.Field("last", "can't be used together with first"))
+	}
+	switch {
+	case args.First != nil:
+		if *args.First < 0 || *args.First > maxPageSize {
+			errs = append(errs, apperrors.Field("first", "must be between 0 and %d", maxPageSize))
+		}
+		page.First = int(*args.First)
+	case args.Last != nil:
+		if *args.Last < 0 || *args.Last > maxPageSize {
+			errs = append(errs, apperrors.Field("last", "must be between 0 and %d", maxPageSize))
+		}
+		page.Last = int(*args.Last)
+	default:
+		page.First = defaultPageSize
+	}
+	var err error
+	if args.After != nil {
+		if page.After, err = decodeCursor(kind, *args.After); err != nil {
+			errs = append(errs, apperrors.Field("after", "%s", err))
+		}
+	}
+	if args.Before != nil {
+		if page.Before, err = decodeCursor(kind, *args.Before); err != nil {
+			errs = append(errs, apperrors.Field("before", "%s", err))
+		}
+	}
+	return page, apperrors.Invalid("", errs)
+}
+
+// newPageInfo builds the page info for a page fetched with page, given whether
+// there were more nodes past the end it was fetched from.
+func newPageInfo(page db.KeysetPage, more bool, startCursor, endCursor *graphql.ID) *PageInfoResolver {
+	info := &PageInfoResolver{startCursor: startCursor, endCursor: endCursor}
+	if page.Last > 0 {
+		info.hasPreviousPage = more
+		info.hasNextPage = page.Before != 0
+	} else {
+		info.hasNextPage = more
+		info.hasPreviousPage = page.After != 0
+	}
+	return info
+}
+
+type PageInfoResolver struct {
+	hasNextPage     bool
+	hasPreviousPage bool
+	startCursor     *graphql.ID
+	endCursor       *graphql.ID
+}
+
+func (r *PageInfoResolver) HasNextPage() bool {
+	return r.hasNextPage
+}
+
+func (r *PageInfoResolver) HasPreviousPage() bool {
+	return r.hasPreviousPage
+}
+
+func (r *PageInfoResolver) StartCursor() *graphql.ID {
+	return r.startCursor
+}
+
+func (r *PageInfoResolver) EndCursor() *graphql.ID {
+	return r.endCursor
+}
+
+const (
+	linkCursorKind = "Link"
+	voteCursorKind = "Vote"
+)
+
+// LinkConnectionResolver is a page of links, newest first.
+type LinkConnectionResolver struct {
+	DB       *db.DB
+	filter   db.LinkFilter
+	links    []*model.Link
+	pageInfo *PageInfoResolver
+}
+
+func newLinkConnection(database *db.DB, filter db.LinkFilter, args ConnectionArgs) (*LinkConnectionResolver, error) {
+	page, err := args.keysetPage(linkCursorKind, true)
+	if err != nil {
+		return nil, err
+	}
+	links, more, err := database.LinksPage(filter, page)
+	if err != nil {
+		return nil, err
+	}
+	var start, end *graphql.ID
+	if len(links) > 0 {
+		first, last := encodeCursor(linkCursorKind, links[0].ID), encodeCursor(linkCursorKind, links[len(links)-1].ID)
+		start, end = &first, &last
+	}
+	return &LinkConnectionResolver{
+		DB:       database,
+		filter:   filter,
+		links:    links,
+		pageInfo: newPageInfo(page, more, start, end),
+	}, nil
+}
+
+func (r *LinkConnectionResolver) Edges() []*LinkEdgeResolver {
+	edges := make([]*LinkEdgeResolver, 0, len(r.links))
+	for _, link := range r.links {
+		edges = append(edges, &LinkEdgeResolver{r.DB, *link})
+	}
+	return edges
+}
+
+func (r *LinkConnectionResolver) PageInfo() *PageInfoResolver {
+	return r.pageInfo
+}
+
+func (r *LinkConnectionResolver) TotalCount() (int32, error) {
+	count, err := r.DB.CountLinks(r.filter)
+	return int32(count), err
+}
+
+type LinkEdgeResolver struct {
+	DB   *db.DB
+	Link model.Link
+}
+
+func (r *LinkEdgeResolver) Cursor() graphql.ID {
+	return encodeCursor(linkCursorKind, r.Link.ID)
+}
+
+func (r *LinkEdgeResolver) Node() *LinkResolver {
+	return &LinkResolver{r.DB, r.Link}
+}
+
+// VoteConnectionResolver is a page of the votes on a link, oldest first.
+type VoteConnectionResolver struct {
+	DB       *db.DB
+	linkID   uint
+	votes    []*model.Vote
+	pageInfo *PageInfoResolver
+}
+
+func newVoteConnection(database *db.DB, linkID uint, args ConnectionArgs) (*VoteConnectionResolver, error) {
+	page, err := args.keysetPage(voteCursorKind, false)
+	if err != nil {
+		return nil, err
+	}
+	votes, more, err := database.VotesPage(linkID, page)
+	if err != nil {
+		return nil, err
+	}
+	var start, end *graphql.ID
+	if len(votes) > 0 {
+		first, last := encodeCursor(voteCursorKind, votes[0].ID), encodeCursor(voteCursorKind, votes[len(votes)-1].ID)
+		start, end = &first, &last
+	}
+	return &VoteConnectionResolver{
+		DB:       database,
+		linkID:   linkID,
+		votes:    votes,
+		pageInfo: newPageInfo(page, more, start, end),
+	}, nil
+}
+
+func (r *VoteConnectionResolver) Edges() []*VoteEdgeResolver {
+	edges := make([]*VoteEdgeResolver, 0, len(r.votes))
+	for _, vote := range r.votes {
+		edges = append(edges, &VoteEdgeResolver{r.DB, *vote})
+	}
+	return edges
+}
+
+func (r *VoteConnectionResolver) PageInfo() *PageInfoResolver {
+	return r.pageInfo
+}
+
+func (r *VoteConnectionResolver) TotalCount() (int32, error) {
+	count, err := r.DB.CountVotesByLinkId(r.linkID)
+	return int32(count), err
+}
+
+type VoteEdgeResolver struct {
+	DB   *db.DB
+	Vote model.Vote
+}
+
+func (r *VoteEdgeResolver) Cursor() graphql.ID {
+	return encodeCursor(voteCursorKind, r.Vote.ID)
+}
+
+func (r *VoteEdgeResolver) Node() *VoteResolver {
+	return &VoteResolver{r.DB, r.Vote}
+}
@@ -0,0 +1,93 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/leggettc18/hackernews-clone-api/auth"
+	"github.com/leggettc18/hackernews-clone-api/db"
+	apperrors "github.com/leggettc18/hackernews-clone-api/errors"
+)
+
+// publicError classifies an error returned by a resolver and returns what
+// clients are shown of it. Errors of unknown kinds are logged and replaced by
+// a generic internal error, so that database and other internal details don't
+// leak.
+func publicError(err error) *apperrors.Error {
+	var (
+		public         *apperrors.Error
+		authentication *auth.AuthenticationError
+		permission     *auth.PermissionError
+	)
+	switch {
+	case errors.As(err, &public):
+		return public
+	case errors.As(err, &authentication):
+		public = apperrors.New(apperrors.Unauthenticated, "%s", authentication.Message)
+		if authentication.RetryAfter > 0 {
+			public.With("retryAfter", int(math.Ceil(authentication.RetryAfter.Seconds())))
+		}
+		return public
+	case errors.As(err, &permission):
+		return apperrors.New(apperrors.Forbidden, "%s", permission.Error())
+	case errors.Is(err, db.ErrDuplicateVote):
+		return apperrors.New(apperrors.Conflict, "%s", db.ErrDuplicateVote.Error())
+	case db.IsNotFound(err):
+		return apperrors.New(apperrors.NotFound, "not found")
+	}
+	log.Println("graphql: internal error:", err)
+	return apperrors.New(apperrors.Internal, "internal error")
+}
+
+// PresentErrors replaces the errors resolvers returned in a response with
+// their public versions, carrying their code in the extensions.
+func PresentErrors(response *graphql.Response) {
+	for _, err := range response.Errors {
+		if err.ResolverError == nil {
+			// Errors in the query itself are already meant for clients.
+			continue
+		}
+		public := publicError(err.ResolverError)
+		err.Message = public.Message
+		err.Extensions = public.Extensions()
+	}
+}
+
+// Schema runs operations on a GraphQL schema, presenting the errors returned
+// by resolvers with PresentErrors.
+type Schema struct {
+	*graphql.Schema
+}
+
+// Exec runs a query or mutation.
+func (s Schema) Exec(ctx context.Context, query, operationName string, variables map[string]interface{}) *graphql.Response {
+	response := s.Schema.Exec(ctx, query, operationName, variables)
+	PresentErrors(response)
+	return response
+}
+
+// Subscribe runs a subscription.
+func (s Schema) Subscribe(ctx context.Context, query, operationName string, variables map[string]interface{}) (<-chan interface{}, error) {
+	results, err := s.Schema.Subscribe(ctx, query, operationName, variables)
+	if err != nil {
+		return nil, err
+	}
+	presented := make(chan interface{})
+	go func() {
+		defer close(presented)
+		for result := range results {
+			if response, ok := result.(*graphql.Response); ok {
+				PresentErrors(response)
+			}
+			select {
+			case presented <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return presented, nil
+}
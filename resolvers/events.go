@@ -0,0 +1,210 @@
+package resolvers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+
+	apperrors "github.com/leggettc18/hackernews-clone-api/errors"
+	"github.com/leggettc18/hackernews-clone-api/model"
+	"github.com/leggettc18/hackernews-clone-api/pubsub"
+)
+
+// subscriptionEvent is implemented by every event type, through EventID.
+type subscriptionEvent interface {
+	eventID() EventID
+}
+
+// replayBatchSize is the most missed events read from the event log at once.
+const replayBatchSize = 100
+
+// voteEventPayload is a newVote event as sent over the bus. Link events send
+// the model.Link itself.
+type voteEventPayload struct {
+	Action string     `json:"action"`
+	Vote   model.Vote `json:"vote"`
+}
+
+// scoreEventPayload is a linkScoreChanged event as sent over the bus.
+type scoreEventPayload struct {
+	LinkID uint `json:"linkId"`
+	Score  int  `json:"score"`
+}
+
+// publish sends an event to the subscribers on every instance sharing the
+// bus. Failures are only logged, since what the event reports already happened.
+func (r *RootResolver) publish(topic string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err == nil {
+		err = r.Bus.Publish(pubsub.Message{Topic: topic, Payload: data})
+	}
+	if err != nil {
+		log.Println("pubsub: unable to publish", topic, "event:", err)
+	}
+}
+
+// deliver hands an event received from the bus to this instance's subscribers.
+func (r *RootResolver) deliver(msg pubsub.Message) {
+	event, err := r.decodeEvent(msg)
+	if err != nil {
+		log.Println("pubsub: unable to decode", msg.Topic, "event:", err)
+		return
+	}
+	r.Events.Publish(msg.Topic, event)
+}
+
+// decodeEvent turns a message from the bus into the event type its topic's
+// subscription resolves.
+func (r *RootResolver) decodeEvent(msg pubsub.Message) (interface{}, error) {
+	switch msg.Topic {
+	case topicNewLink, topicLinkUpdated, topicLinkDeleted:
+		var link model.Link
+		if err := json.Unmarshal(msg.Payload, &link); err != nil {
+			return nil, err
+		}
+		linkResolver := &LinkResolver{DB: r.DB, Link: link}
+		if msg.Topic == topicNewLink {
+			return &NewLinkEvent{EventID: EventID(msg.ID), Link: linkResolver}, nil
+		}
+		return &LinkEvent{EventID: EventID(msg.ID), link: linkResolver}, nil
+	case topicNewVote:
+		var payload voteEventPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return &NewVoteEvent{
+			EventID:    EventID(msg.ID),
+			VoteAction: payload.Action,
+			Vote:       &VoteResolver{DB: r.DB, Vote: payload.Vote},
+		}, nil
+	case topicLinkScoreChanged:
+		var payload scoreEventPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return &LinkScoreEvent{
+			EventID:  EventID(msg.ID),
+			DB:       r.DB,
+			LinkID:   payload.LinkID,
+			NewScore: payload.Score,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown topic %q", msg.Topic)
+}
+
+// subscribe returns the events published on topic from now on that filter
+// accepts. Given the ID of the last event a client received, the events it
+// missed since are replayed from the event log first.
+func (r *RootResolver) subscribe(ctx context.Context, op, topic string, lastEventID *string, filter pubsub.Filter) (<-chan interface{}, error) {
+	if lastEventID == nil {
+		return r.Events.Subscribe(ctx, topic, filter).Events(), nil
+	}
+	after, err := strconv.ParseUint(*lastEventID, 10, 32)
+	if err != nil {
+		return nil, apperrors.Invalid(op, apperrors.Errors{apperrors.Field("lastEventId", "isn't a valid event ID")})
+	}
+	missed, err := r.EventLog.Since(topic, uint(after), replayBatchSize)
+	switch err {
+	case nil:
+	case pubsub.ErrUnknownEvent:
+		return nil, apperrors.Invalid(op, apperrors.Errors{apperrors.Field("lastEventId", "is newer than the latest event")})
+	case pubsub.ErrEventsExpired:
+		return nil, apperrors.Invalid(op, apperrors.Errors{apperrors.Field("lastEventId", "is older than the events still kept")})
+	default:
+		return nil, err
+	}
+
+	events := make(chan interface{})
+	go func() {
+		defer close(events)
+		send := func(event interface{}) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// replay sends the events in missed and the ones after them in the
+		// log, until a read comes back short. cursor is the last one read.
+		cursor := uint(after)
+		replay := func(missed []pubsub.Message) bool {
+			for {
+				for _, msg := range missed {
+					cursor = msg.ID
+					event, err := r.decodeEvent(msg)
+					if err != nil {
+						log.Println("pubsub: unable to decode", msg.Topic, "event:", err)
+						continue
+					}
+					if filter != nil && !filter(event) {
+						continue
+					}
+					if !send(event) {
+						return false
+					}
+				}
+				if len(missed) < replayBatchSize {
+					return true
+				}
+				var err error
+				if missed, err = r.EventLog.Since(topic, cursor, replayBatchSize); err != nil {
+					log.Println("pubsub: unable to replay", topic, "events:", err)
+					return false
+				}
+			}
+		}
+
+		// Catch up before subscribing, so that a long replay can't overflow
+		// the live subscription's buffer.
+		if !replay(missed) {
+			return
+		}
+		// Then subscribe and read what was published since the last read, so
+		// that nothing is missed in between. Events that show up in both are
+		// only sent once.
+		live := r.Events.Subscribe(ctx, topic, filter)
+		defer live.Close()
+		missed, err := r.EventLog.Since(topic, cursor, replayBatchSize)
+		if err != nil {
+			log.Println("pubsub: unable to replay", topic, "events:", err)
+			return
+		}
+		if !replay(missed) {
+			return
+		}
+
+		for event := range live.Events() {
+			if uint(event.(subscriptionEvent).eventID()) <= cursor {
+				continue
+			}
+			if !send(event) {
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// forward passes the events from subscribe on to c, a channel of the event
+// type a subscription resolves, until events is closed or ctx is done. It then
+// closes c.
+func forward(ctx context.Context, events <-chan interface{}, c interface{}) {
+	out := reflect.ValueOf(c)
+	go func() {
+		defer out.Close()
+		for event := range events {
+			cases := []reflect.SelectCase{
+				{Dir: reflect.SelectSend, Chan: out, Send: reflect.ValueOf(event)},
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			}
+			if chosen, _, _ := reflect.Select(cases); chosen == 1 {
+				return
+			}
+		}
+	}()
+}
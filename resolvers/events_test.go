@@ -0,0 +1,153 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package resolvers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/leggettc18/hackernews-clone-api/config"
+	"github.com/leggettc18/hackernews-clone-api/db"
+)
+
+// newTestRoot returns a root resolver over a temporary database, with
+// subscriptions that are disconnected as soon as their buffer of two events
+// overflows.
+func newTestRoot(t *testing.T) *RootResolver {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "hackernews-clone-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(dir, "db.sqlite")
+	cfg.Auth.JWTSecret = "0123456789abcdef0123"
+	cfg.Subscriptions.BufferSize = 2
+	cfg.Subscriptions.Overflow = "disconnect"
+	database, err := db.NewDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	r, err := NewRoot(ctx, database, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func publishScores(r *RootResolver, from, to int) {
+	for score := from; score < to; score++ {
+		r.publish(topicLinkScoreChanged, scoreEventPayload{LinkID: 1, Score: score})
+	}
+}
+
+// receiveScores reads n events from events and checks that they are the
+// scores from on, in order.
+func receiveScores(t *testing.T, events <-chan interface{}, from, n int) {
+	t.Helper()
+	for score := from; score < from+n; score++ {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("subscription ended before score %d", score)
+			}
+			if got := event.(*LinkScoreEvent).NewScore; got != score {
+				t.Fatalf("got score %d, want %d", got, score)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event for score %d", score)
+		}
+	}
+}
+
+func TestSubscribeReplaysMissedEvents(t *testing.T) {
+	r := newTestRoot(t)
+	publishScores(r, 0, 5)
+	first, err := r.EventLog.DB.LatestEventId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// More missed events than a replay batch.
+	publishScores(r, 5, 5+2*replayBatchSize+10)
+	missed := 2*replayBatchSize + 10
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lastEventID := strconv.Itoa(int(first))
+	events, err := r.subscribe(ctx, "linkScoreChanged", topicLinkScoreChanged, &lastEventID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiveScores(t, events, 5, 1)
+	// Events published while replaying are replayed too, instead of piling
+	// up in the live subscription's small buffer.
+	publishScores(r, 5+missed, 5+missed+50)
+	receiveScores(t, events, 6, missed-1+50)
+
+	// Then new events arrive live.
+	for score := 5 + missed + 50; score < 5+missed+55; score++ {
+		publishScores(r, score, score+1)
+		receiveScores(t, events, score, 1)
+	}
+}
+
+func TestSubscribeFiltersReplayedEvents(t *testing.T) {
+	r := newTestRoot(t)
+	publishScores(r, 0, 10)
+	even := func(event interface{}) bool { return event.(*LinkScoreEvent).NewScore%2 == 0 }
+	lastEventID := "0"
+	events, err := r.subscribe(context.Background(), "linkScoreChanged", topicLinkScoreChanged, &lastEventID, even)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for score := 0; score < 10; score += 2 {
+		receiveScores(t, events, score, 1)
+	}
+	publishScores(r, 10, 12)
+	receiveScores(t, events, 10, 1)
+}
+
+func TestSubscribeRejectsLastEventID(t *testing.T) {
+	r := newTestRoot(t)
+	publishScores(r, 0, 3)
+	latest, err := r.EventLog.DB.LatestEventId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscribe := func(lastEventID string) error {
+		_, err := r.subscribe(context.Background(), "linkScoreChanged", topicLinkScoreChanged, &lastEventID, nil)
+		return err
+	}
+
+	tests := map[string]string{
+		"abc":                         "linkScoreChanged: lastEventId: isn't a valid event ID",
+		"-1":                          "linkScoreChanged: lastEventId: isn't a valid event ID",
+		strconv.Itoa(int(latest) + 1): "linkScoreChanged: lastEventId: is newer than the latest event",
+	}
+	for lastEventID, want := range tests {
+		if err := subscribe(lastEventID); err == nil || err.Error() != want {
+			t.Errorf("lastEventId %q: got %v, want %q", lastEventID, err, want)
+		}
+	}
+
+	if err := r.EventLog.DB.DeleteEventsBefore(time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	want := "linkScoreChanged: lastEventId: is older than the events still kept"
+	if err := subscribe(strconv.Itoa(int(latest) - 1)); err == nil || err.Error() != want {
+		t.Errorf("lastEventId before deleted events: got %v, want %q", err, want)
+	}
+	if err := subscribe(strconv.Itoa(int(latest))); err != nil {
+		t.Errorf("lastEventId of the latest deleted event: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package resolvers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/leggettc18/hackernews-clone-api/db"
+	apperrors "github.com/leggettc18/hackernews-clone-api/errors"
+)
+
+type FeedArgs struct {
+	First *int32
+	Skip  *int32
+	Type  *string
+}
+
+// FrontPage returns recent links by their time-decayed rank.
+func (r RootResolver) FrontPage(args FeedArgs) ([]*LinkResolver, error) {
+	return r.feed(args, db.OrderRankDesc, db.LinkFilter{
+		CreatedSince: time.Now().Add(-r.Config.Ranking.Window),
+	})
+}
+
+// Newest returns links newest first.
+func (r RootResolver) Newest(args FeedArgs) ([]*LinkResolver, error) {
+	return r.feed(args, db.OrderCreatedAtDesc, db.LinkFilter{})
+}
+
+// Best returns the links posted within the ranking window, most points first,
+// ignoring age within the window.
+func (r RootResolver) Best(args FeedArgs) ([]*LinkResolver, error) {
+	return r.feed(args, db.OrderScoreDesc, db.LinkFilter{
+		CreatedSince: time.Now().Add(-r.Config.Ranking.Window),
+	})
+}
+
+// Active returns links with recent comments, most recently commented first.
+func (r RootResolver) Active(args FeedArgs) ([]*LinkResolver, error) {
+	return r.feed(args, db.OrderActivityDesc, db.LinkFilter{
+		ActiveSince: time.Now().Add(-r.Config.Ranking.Window),
+	})
+}
+
+func (r RootResolver) feed(args FeedArgs, order string, filter db.LinkFilter) ([]*LinkResolver, error) {
+	if args.Type != nil {
+		filter.Type = strings.ToLower(*args.Type)
+	}
+	query := db.LinkQuery{LinkFilter: filter, OrderBy: order, First: defaultPageSize}
+	var errs apperrors.Errors
+	if args.First != nil {
+		if *args.First < 0 || *args.First > maxPageSize {
+			errs = append(errs, apperrors.Field("first", "must be between 0 and %d", maxPageSize))
+		}
+		query.First = int(*args.First)
+	}
+	if args.Skip != nil {
+		if *args.Skip < 0 {
+			errs = append(errs, apperrors.Field("skip", "can't be negative"))
+		}
+		query.Skip = int(*args.Skip)
+	}
+	if err := apperrors.Invalid("feed", errs); err != nil {
+		return nil, err
+	}
+	if query.First == 0 {
+		return []*LinkResolver{}, nil
+	}
+	links, err := r.DB.ListLinks(query)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*LinkResolver, 0, len(links))
+	for _, link := range links {
+		resolvers = append(resolvers, &LinkResolver{r.DB, *link})
+	}
+	return resolvers, nil
+}
@@ -0,0 +1,50 @@
+package resolvers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestForward(t *testing.T) {
+	events := make(chan interface{}, 2)
+	c := make(chan *LinkScoreEvent)
+	forward(context.Background(), events, c)
+
+	events <- &LinkScoreEvent{NewScore: 1}
+	events <- &LinkScoreEvent{NewScore: 2}
+	close(events)
+	for _, want := range []int{1, 2} {
+		if event := <-c; event.NewScore != want {
+			t.Errorf("got score %d, want %d", event.NewScore, want)
+		}
+	}
+	select {
+	case _, ok := <-c:
+		if ok {
+			t.Error("got an event after the last one")
+		}
+	case <-time.After(time.Second):
+		t.Error("channel not closed after the events ended")
+	}
+}
+
+func TestForwardStopsWithContext(t *testing.T) {
+	events := make(chan interface{}, 1)
+	c := make(chan *LinkScoreEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	forward(ctx, events, c)
+
+	// Nobody reads c, so forwarding blocks until ctx is done.
+	events <- &LinkScoreEvent{NewScore: 1}
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case _, ok := <-c:
+		if ok {
+			t.Error("got an event after the context was cancelled")
+		}
+	case <-time.After(time.Second):
+		t.Error("channel not closed after the context was cancelled")
+	}
+}
@@ -1,10 +1,14 @@
 package resolvers
 
 import (
+	"context"
 	"fmt"
 	"github.com/graph-gophers/graphql-go"
 	"github.com/leggettc18/hackernews-clone-api/db"
+	"github.com/leggettc18/hackernews-clone-api/linkurl"
+	"github.com/leggettc18/hackernews-clone-api/markdown"
 	"github.com/leggettc18/hackernews-clone-api/model"
+	"strings"
 )
 
 type LinkResolver struct {
@@ -20,20 +24,83 @@ func (r *LinkResolver) CreatedAt() graphql.Time {
 	return graphql.Time{Time: r.Link.CreatedAt}
 }
 
+// deletedDescription replaces the description of deleted links.
+const deletedDescription = "[deleted]"
+
+// Description is the link's title, or [deleted] once it was deleted.
 func (r *LinkResolver) Description() string {
+	if r.Link.DeletedAt != nil {
+		return deletedDescription
+	}
 	return r.Link.Description
 }
 
+// Url is where the link points to, or empty once it was deleted.
 func (r *LinkResolver) Url() string {
+	if r.Link.DeletedAt != nil {
+		return ""
+	}
 	return r.Link.Url
 }
 
-func (r *LinkResolver) PostedBy() (*UserResolver, error) {
-	user, err := r.DB.GetUserById(r.Link.PosterID)
+// Domain is the host the link points to without any www. prefix, or empty
+// once it was deleted.
+func (r *LinkResolver) Domain() string {
+	if r.Link.DeletedAt != nil {
+		return ""
+	}
+	return linkurl.Domain(r.Link.Url)
+}
+
+// Type is the kind of post as a PostType enum value.
+func (r *LinkResolver) Type() string {
+	return strings.ToUpper(r.Link.Type)
+}
+
+// Body is the Markdown text of the post, or null if it has none or was deleted.
+func (r *LinkResolver) Body() *string {
+	if r.Link.Body == "" || r.Link.DeletedAt != nil {
+		return nil
+	}
+	return &r.Link.Body
+}
+
+// BodyHtml is the body rendered as sanitized HTML.
+func (r *LinkResolver) BodyHtml() *string {
+	body := r.Body()
+	if body == nil {
+		return nil
+	}
+	html := markdown.ToHTML(*body)
+	return &html
+}
+
+func (r *LinkResolver) UpdatedAt() *graphql.Time {
+	if r.Link.UpdatedAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.Link.UpdatedAt}
+}
+
+func (r *LinkResolver) DeletedAt() *graphql.Time {
+	if r.Link.DeletedAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.Link.DeletedAt}
+}
+
+// Score is the sum of the votes on the link.
+func (r *LinkResolver) Score() (int32, error) {
+	score, err := r.DB.GetLinkScore(r.Link.ID)
+	return int32(score), err
+}
+
+func (r *LinkResolver) PostedBy(ctx context.Context) (*UserResolver, error) {
+	user, err := loadUser(ctx, r.DB, r.Link.PosterID)
 	if err != nil {
 		return nil, err
 	}
-	return &UserResolver{r.DB, *user}, nil
+	return &UserResolver{DB: r.DB, User: *user}, nil
 }
 
 func (r *LinkResolver) Votes() (*[]*VoteResolver, error) {
@@ -51,3 +118,23 @@ func (r *LinkResolver) Votes() (*[]*VoteResolver, error) {
 	}
 	return &resolvers, nil
 }
+
+// VotesConnection returns a page of the votes on the link, oldest first.
+func (r *LinkResolver) VotesConnection(args ConnectionArgs) (*VoteConnectionResolver, error) {
+	return newVoteConnection(r.DB, r.Link.ID, args)
+}
+
+// Comments returns the top-level comments on the link, oldest first. The whole
+// thread is loaded at once, so replies are resolved without further queries.
+func (r *LinkResolver) Comments() ([]*CommentResolver, error) {
+	comments, err := r.DB.GetCommentsByLinkId(r.Link.ID)
+	if err != nil {
+		return nil, err
+	}
+	return newCommentThread(comments).resolvers(r.DB, 0), nil
+}
+
+func (r *LinkResolver) CommentCount() (int32, error) {
+	count, err := r.DB.CountCommentsByLinkId(r.Link.ID)
+	return int32(count), err
+}
@@ -0,0 +1,27 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/leggettc18/hackernews-clone-api/db"
+	"github.com/leggettc18/hackernews-clone-api/loader"
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+// loadUser returns the user with the given ID, batched with the other lookups
+// of the request if it has loaders attached.
+func loadUser(ctx context.Context, database *db.DB, id uint) (*model.User, error) {
+	if loaders := loader.For(ctx); loaders != nil {
+		return loaders.Users.Load(id)
+	}
+	return database.GetUserById(id)
+}
+
+// loadLink returns the link with the given ID, deleted or not, batched with
+// the other lookups of the request if it has loaders attached.
+func loadLink(ctx context.Context, database *db.DB, id uint) (*model.Link, error) {
+	if loaders := loader.For(ctx); loaders != nil {
+		return loaders.Links.Load(id)
+	}
+	return database.GetLinkByIdUnscoped(id)
+}
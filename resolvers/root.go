@@ -2,163 +2,291 @@ package resolvers
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"github.com/graph-gophers/graphql-go"
+	"github.com/leggettc18/hackernews-clone-api/auth"
+	"github.com/leggettc18/hackernews-clone-api/config"
 	"github.com/leggettc18/hackernews-clone-api/db"
+	apperrors "github.com/leggettc18/hackernews-clone-api/errors"
+	"github.com/leggettc18/hackernews-clone-api/linkurl"
 	"github.com/leggettc18/hackernews-clone-api/model"
+	"github.com/leggettc18/hackernews-clone-api/pubsub"
+	"github.com/leggettc18/hackernews-clone-api/ranking"
 	"golang.org/x/crypto/bcrypt"
-	"math/rand"
-	"sort"
+	"log"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type RootResolver struct {
-	DB                *db.DB
-	NewLinkEvents     chan *NewLinkEvent
-	NewLinkSubscriber chan *NewLinkSubscriber
-	NewVoteEvents     chan *NewVoteEvent
-	NewVoteSubscriber chan *NewVoteSubscriber
+	DB            *db.DB
+	Config        *config.Config
+	LoginThrottle *auth.LoginThrottle
+	Ranker        *ranking.Ranker
+	// Bus carries subscription events between the instances sharing it.
+	// Events received from it are delivered to this instance's subscribers
+	// through Events, on the topics below. EventLog keeps them for replay.
+	Bus      pubsub.Bus
+	Events   *pubsub.Hub
+	EventLog *pubsub.EventLog
 }
 
-type NewLinkSubscriber struct {
-	stop   <-chan struct{}
-	events chan<- *NewLinkEvent
+// Topics of the events published to Events.
+const (
+	topicNewLink     = "newLink"
+	topicNewVote     = "newVote"
+	topicLinkUpdated = "linkUpdated"
+	topicLinkDeleted = "linkDeleted"
+	// Published along with newVote, since every vote changes a link's score.
+	topicLinkScoreChanged = "linkScoreChanged"
+)
+
+// EventID identifies a subscription event. IDs increase in the order events
+// were published, on every topic.
+type EventID uint
+
+// ID is the event's ID, which clients pass as lastEventId to resume after it.
+func (id EventID) ID() string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func (id EventID) eventID() EventID {
+	return id
 }
 
 type NewLinkEvent struct {
-	EventID string
-	Link    *LinkResolver
+	EventID
+	Link *LinkResolver
 }
 
 func (r *NewLinkEvent) NewLink() *LinkResolver {
 	return r.Link
 }
 
-func (r *NewLinkEvent) ID() string {
-	return r.EventID
-}
+// Values of the VoteAction enum, telling subscribers what happened to a vote.
+const (
+	VoteActionCast      = "CAST"
+	VoteActionChanged   = "CHANGED"
+	VoteActionRetracted = "RETRACTED"
+)
 
 type NewVoteEvent struct {
-	EventID string
-	Vote    *VoteResolver
+	EventID
+	VoteAction string
+	Vote       *VoteResolver
 }
 
 func (r *NewVoteEvent) NewVote() *VoteResolver {
 	return r.Vote
 }
 
-func (r *NewVoteEvent) ID() string {
-	return r.EventID
+func (r *NewVoteEvent) Action() string {
+	return r.VoteAction
+}
+
+// LinkEvent tells subscribers that a link was edited or deleted.
+type LinkEvent struct {
+	EventID
+	link *LinkResolver
+}
+
+func (r *LinkEvent) Link() *LinkResolver {
+	return r.link
 }
 
-type NewVoteSubscriber struct {
-	stop   <-chan struct{}
-	events chan<- *NewVoteEvent
+// LinkScoreEvent tells subscribers that votes changed a link's score.
+type LinkScoreEvent struct {
+	EventID
+	DB       *db.DB
+	LinkID   uint
+	NewScore int
 }
 
-func NewRoot(db *db.DB) (*RootResolver, error) {
+func (r *LinkScoreEvent) Link(ctx context.Context) (*LinkResolver, error) {
+	link, err := loadLink(ctx, r.DB, r.LinkID)
+	if err != nil {
+		return nil, err
+	}
+	return &LinkResolver{DB: r.DB, Link: *link}, nil
+}
+
+// Score is the link's score after the vote that changed it.
+func (r *LinkScoreEvent) Score() int32 {
+	return int32(r.NewScore)
+}
+
+// NewRoot returns the root resolver and starts its background work: ranking
+// links, pruning old events and delivering events from the bus. The work
+// stops when ctx is done.
+func NewRoot(ctx context.Context, db *db.DB, cfg *config.Config) (*RootResolver, error) {
+	loginThrottle := &auth.LoginThrottle{
+		Accounts: auth.NewLimiter(cfg.Auth.MaxLoginAttempts, cfg.Auth.LoginAttemptWindow, cfg.Auth.LockoutDuration),
+		IPs:      auth.NewLimiter(cfg.Auth.MaxLoginAttemptsPerIP, cfg.Auth.LoginAttemptWindow, cfg.Auth.LockoutDuration),
+	}
+	overflow, err := pubsub.ParsePolicy(cfg.Subscriptions.Overflow)
+	if err != nil {
+		return nil, err
+	}
 	r := &RootResolver{
-		DB:                db,
-		NewLinkEvents:     make(chan *NewLinkEvent),
-		NewLinkSubscriber: make(chan *NewLinkSubscriber),
-		NewVoteEvents:     make(chan *NewVoteEvent),
-		NewVoteSubscriber: make(chan *NewVoteSubscriber),
+		DB:            db,
+		Config:        cfg,
+		LoginThrottle: loginThrottle,
+		Ranker:        ranking.NewRanker(db, cfg.Ranking),
+		Events:        pubsub.NewHub(cfg.Subscriptions.BufferSize, overflow),
+	}
+	r.EventLog = pubsub.NewEventLog(db, cfg.Subscriptions.Retention)
+	switch cfg.Subscriptions.Bus {
+	case "database":
+		r.Bus = pubsub.NewOutboxBus(r.EventLog, cfg.Subscriptions.PollInterval)
+	default:
+		r.Bus = pubsub.NewMemoryBus(r.EventLog)
+	}
+	if err := r.Bus.Listen(ctx, r.deliver); err != nil {
+		return nil, err
 	}
 
-	go r.broadcastNewLink()
-	go r.broadcastNewVote()
+	go r.Ranker.Run(ctx)
+	go r.EventLog.Run(ctx)
 
 	return r, nil
 }
 
-func (r *RootResolver) broadcastNewLink() {
-	subscribers := map[string]*NewLinkSubscriber{}
-	unsubscribe := make(chan string)
-
-	for {
-		select {
-		case id := <-unsubscribe:
-			delete(subscribers, id)
-		case s := <-r.NewLinkSubscriber:
-			subscribers[randomID()] = s
-		case e := <-r.NewLinkEvents:
-			for id, s := range subscribers {
-				go func(id string, s *NewLinkSubscriber) {
-					select {
-					case <-s.stop:
-						unsubscribe <- id
-						return
-					default:
-					}
-
-					select {
-					case <-s.stop:
-						unsubscribe <- id
-					case s.events <- e:
-					case <-time.After(time.Second):
-					}
-				}(id, s)
-			}
+// SubscriptionArgs are the arguments every subscription takes.
+type SubscriptionArgs struct {
+	// LastEventID resumes a subscription after the event with this ID,
+	// replaying the events missed since.
+	LastEventID *string
+}
+
+type NewLinkSubscriptionArgs struct {
+	LastEventID *string
+	Terms       *[]string
+	Type        *string
+}
+
+// NewLink streams links as they are posted, only those containing one of the
+// terms in their description or url if any are given, and only those of the
+// given type if one is.
+func (r *RootResolver) NewLink(ctx context.Context, args NewLinkSubscriptionArgs) (<-chan *NewLinkEvent, error) {
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+	var (
+		terms    []string
+		postType string
+	)
+	if args.Terms != nil {
+		for _, term := range *args.Terms {
+			terms = append(terms, strings.ToLower(strings.TrimSpace(term)))
 		}
 	}
+	if args.Type != nil {
+		postType = strings.ToLower(*args.Type)
+	}
+	filter := func(event interface{}) bool {
+		link := event.(*NewLinkEvent).Link.Link
+		return (postType == "" || link.Type == postType) && linkContainsAny(link, terms)
+	}
+	events, err := r.subscribe(ctx, "newLink", topicNewLink, args.LastEventID, filter)
+	if err != nil {
+		return nil, err
+	}
+	c := make(chan *NewLinkEvent)
+	forward(ctx, events, c)
+	return c, nil
 }
 
-func (r *RootResolver) broadcastNewVote() {
-	subscribers := map[string]*NewVoteSubscriber{}
-	unsubscribe := make(chan string)
-
-	for {
-		select {
-		case id := <-unsubscribe:
-			delete(subscribers, id)
-		case s := <-r.NewVoteSubscriber:
-			subscribers[randomID()] = s
-		case e := <-r.NewVoteEvents:
-			for id, s := range subscribers {
-				go func(id string, s *NewVoteSubscriber) {
-					select {
-					case <-s.stop:
-						unsubscribe <- id
-						return
-					default:
-					}
-
-					select {
-					case <-s.stop:
-						unsubscribe <- id
-					case s.events <- e:
-					case <-time.After(time.Second):
-					}
-				}(id, s)
-			}
+// linkContainsAny reports whether the link's description or url contains one
+// of terms, ignoring case. Terms must be lower case. Every link contains an
+// empty list.
+func linkContainsAny(link model.Link, terms []string) bool {
+	if len(terms) == 0 {
+		return true
+	}
+	description, url := strings.ToLower(link.Description), strings.ToLower(link.Url)
+	for _, term := range terms {
+		if strings.Contains(description, term) || strings.Contains(url, term) {
+			return true
 		}
 	}
+	return false
 }
 
-func randomID() string {
-	var letter = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+type NewVoteSubscriptionArgs struct {
+	LastEventID *string
+	LinkID      *graphql.ID
+}
 
-	b := make([]rune, 16)
-	for i := range b {
-		b[i] = letter[rand.Intn(len(letter))]
+// NewVote streams votes as they are cast, changed or retracted, only those on
+// the given link if one is.
+func (r *RootResolver) NewVote(ctx context.Context, args NewVoteSubscriptionArgs) (<-chan *NewVoteEvent, error) {
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+	var filter pubsub.Filter
+	if args.LinkID != nil {
+		linkID, _ := getUintFromGraphqlId(*args.LinkID)
+		filter = func(event interface{}) bool {
+			return event.(*NewVoteEvent).Vote.Vote.LinkID == linkID
+		}
 	}
-	return string(b)
+	events, err := r.subscribe(ctx, "newVote", topicNewVote, args.LastEventID, filter)
+	if err != nil {
+		return nil, err
+	}
+	c := make(chan *NewVoteEvent)
+	forward(ctx, events, c)
+	return c, nil
 }
 
-func (r *RootResolver) NewLink(ctx context.Context) (<-chan *NewLinkEvent, error) {
-	fmt.Println("subscribing to new links")
-	c := make(chan *NewLinkEvent)
-	r.NewLinkSubscriber <- &NewLinkSubscriber{events: c, stop: ctx.Done()}
+// LinkUpdated streams links as they are edited.
+func (r *RootResolver) LinkUpdated(ctx context.Context, args SubscriptionArgs) (<-chan *LinkEvent, error) {
+	return r.subscribeLinkEvents(ctx, "linkUpdated", topicLinkUpdated, args.LastEventID)
+}
+
+// LinkDeleted streams links as they are deleted.
+func (r *RootResolver) LinkDeleted(ctx context.Context, args SubscriptionArgs) (<-chan *LinkEvent, error) {
+	return r.subscribeLinkEvents(ctx, "linkDeleted", topicLinkDeleted, args.LastEventID)
+}
+
+func (r *RootResolver) subscribeLinkEvents(ctx context.Context, op, topic string, lastEventID *string) (<-chan *LinkEvent, error) {
+	events, err := r.subscribe(ctx, op, topic, lastEventID, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := make(chan *LinkEvent)
+	forward(ctx, events, c)
 	return c, nil
 }
 
-func (r *RootResolver) NewVote(ctx context.Context) (<-chan *NewVoteEvent, error) {
-	fmt.Println("subscribing to new links")
-	c := make(chan *NewVoteEvent)
-	r.NewVoteSubscriber <- &NewVoteSubscriber{events: c, stop: ctx.Done()}
+type LinkScoreChangedArgs struct {
+	LastEventID *string
+	LinkIDs     *[]graphql.ID
+}
+
+// LinkScoreChanged streams the scores of links as votes change them, only of
+// the given links if any are.
+func (r *RootResolver) LinkScoreChanged(ctx context.Context, args LinkScoreChangedArgs) (<-chan *LinkScoreEvent, error) {
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+	var filter pubsub.Filter
+	if args.LinkIDs != nil {
+		linkIDs := map[uint]bool{}
+		for _, id := range *args.LinkIDs {
+			linkID, _ := getUintFromGraphqlId(id)
+			linkIDs[linkID] = true
+		}
+		filter = func(event interface{}) bool {
+			return linkIDs[event.(*LinkScoreEvent).LinkID]
+		}
+	}
+	events, err := r.subscribe(ctx, "linkScoreChanged", topicLinkScoreChanged, args.LastEventID, filter)
+	if err != nil {
+		return nil, err
+	}
+	c := make(chan *LinkScoreEvent)
+	forward(ctx, events, c)
 	return c, nil
 }
 
@@ -183,142 +311,496 @@ func (r RootResolver) Link(args LinkQueryArgs) (*LinkResolver, error) {
 	return &linkResolver, nil
 }
 
+// Me returns the user the request's access token was issued to, or nil if the
+// request has no token.
+func (r RootResolver) Me(ctx context.Context) (*UserResolver, error) {
+	principal, err := auth.RequireUser(ctx)
+	if err == auth.ErrNotAuthenticated {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := loadUser(ctx, r.DB, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &UserResolver{DB: r.DB, User: *user, self: true}, nil
+}
+
+type UserQueryArgs struct {
+	ID graphql.ID
+}
+
+// User returns the user with the given ID, or nil if there is none.
+func (r RootResolver) User(ctx context.Context, args UserQueryArgs) (*UserResolver, error) {
+	id, err := getUintFromGraphqlId(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := loadUser(ctx, r.DB, id)
+	if db.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &UserResolver{DB: r.DB, User: *user}, nil
+}
+
+type UserByNameQueryArgs struct {
+	Name string
+}
+
+// UserByName returns the user with the given name, or nil if there is none.
+func (r RootResolver) UserByName(args UserByNameQueryArgs) (*UserResolver, error) {
+	user, err := r.DB.GetUserByName(args.Name)
+	if db.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &UserResolver{DB: r.DB, User: *user}, nil
+}
+
 type PostArgs struct {
 	Description string
-	Url         string
+	Url         *string
+	Type        string
+	Body        *string
 }
 
+// maxPostBodyLength is the longest body a post can have, in bytes.
+const maxPostBodyLength = 40000
+
+// Post submits a link. Resubmitting a url posted within the duplicate window
+// returns the existing link instead, upvoted for the submitter.
 func (r *RootResolver) Post(ctx context.Context, args PostArgs) (*LinkResolver, error) {
-	token, ok := ctx.Value("token").(string)
-	if !ok {
-		return &LinkResolver{}, errors.New("post: no key 'token' in context")
-	}
-	author, errAuthor := r.DB.GetUserFromToken(token)
+	author, errAuthor := auth.RequireUser(ctx)
 	if errAuthor != nil {
 		return &LinkResolver{}, errAuthor
 	}
+	postType := strings.ToLower(args.Type)
+	url, errUrl := postUrl(args.Url)
+	var body string
+	if args.Body != nil {
+		body = strings.TrimSpace(*args.Body)
+	}
+	errs := fieldErrors(checkText("description", args.Description, maxDescriptionLength))
+	errs = append(errs, checkPost(postType, url, body, errUrl)...)
+	if err := apperrors.Invalid("post", errs); err != nil {
+		return nil, err
+	}
+	if url != "" && r.Config.Links.DuplicateWindow > 0 {
+		existing, err := r.DB.FindRecentLinkByUrl(linkurl.Equivalents(url), time.Now().Add(-r.Config.Links.DuplicateWindow))
+		switch {
+		case err == nil:
+			return r.resubmit(author.UserID, existing)
+		case !db.IsNotFound(err):
+			return nil, err
+		}
+	}
+
 	newLink := model.Link{
 		CreatedAt:   time.Now(),
-		Description: args.Description,
-		Url:         args.Url,
-		PosterID:    author.ID,
+		Description: strings.TrimSpace(args.Description),
+		Url:         url,
+		Type:        postType,
+		Body:        body,
+		PosterID:    author.UserID,
 		Votes:       []model.Vote{},
 	}
 
 	if err := r.DB.CreateLink(&newLink); err != nil {
 		return nil, err
 	}
+	r.refreshRank(newLink.ID)
 	linkResolver := &LinkResolver{DB: r.DB, Link: newLink}
 
-	select {
-	case r.NewLinkEvents <- &NewLinkEvent{Link: linkResolver, EventID: randomID()}:
-		// values are being read from r.Events
-		fmt.Println("r.NewLinks: inserted link")
-	default:
-		//no subscribers, link not in channel
-		fmt.Println("r.NewLinks: link created, not inserted")
-	}
+	r.publish(topicNewLink, newLink)
 
 	return linkResolver, nil
 }
 
+// resubmit handles a user posting a link that already exists by upvoting it
+// for them, unless they posted it or already upvoted it.
+func (r *RootResolver) resubmit(userID uint, link *model.Link) (*LinkResolver, error) {
+	if link.PosterID != userID {
+		if _, err := r.applyVote(userID, link, model.Upvote); err != nil && err != db.ErrDuplicateVote {
+			return nil, err
+		}
+	}
+	return &LinkResolver{DB: r.DB, Link: *link}, nil
+}
+
+// postUrl normalizes the url submitted with a post, reporting invalid ones as
+// errors on the url argument. A missing or blank url is returned as "".
+func postUrl(raw *string) (string, error) {
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return "", nil
+	}
+	url, err := linkurl.Normalize(*raw)
+	if invalid, ok := err.(*linkurl.Error); ok {
+		return "", apperrors.Field("url", "%s", invalid.Reason)
+	}
+	if err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// checkPost checks that a post has what its type needs: links a url, show
+// and job posts a url or a body, and ask posts no url. errUrl is the error
+// normalizing the url, which is reported instead of the url's other problems.
+func checkPost(postType, url, body string, errUrl error) apperrors.Errors {
+	var errs apperrors.Errors
+	switch {
+	case errUrl != nil:
+		errs = append(errs, errUrl)
+	case postType == model.PostTypeLink && url == "":
+		errs = append(errs, apperrors.Field("url", "links need a url"))
+	case postType == model.PostTypeAsk && url != "":
+		errs = append(errs, apperrors.Field("url", "ask posts can't have a url"))
+	case (postType == model.PostTypeShow || postType == model.PostTypeJob) && url == "" && body == "":
+		errs = append(errs, apperrors.Field("body", "%s posts need a url or a body", postType))
+	}
+	if len(body) > maxPostBodyLength {
+		errs = append(errs, apperrors.Field("body", "can't be longer than %d bytes", maxPostBodyLength))
+	}
+	return errs
+}
+
 type LinksQueryArgs struct {
 	Or      *[]string
 	And     *[]string
-	First   *float64
-	Skip    *float64
+	First   *int32
+	Skip    *int32
 	OrderBy *string
 }
 
-func (r RootResolver) LinksMeta() (*MetaResolver, error) {
-	var links []model.Link
-	result := r.DB.Find(&links)
-	err := result.Error
+type LinksMetaArgs struct {
+	Or  *[]string
+	And *[]string
+}
+
+func (r RootResolver) LinksMeta(args LinksMetaArgs) (*MetaResolver, error) {
+	filter, errs := linkFilter(args.Or, args.And)
+	if err := apperrors.Invalid("linksMeta", errs); err != nil {
+		return nil, err
+	}
+	count, err := r.DB.CountLinks(filter)
 	if err != nil {
 		return nil, err
 	}
-	return &MetaResolver{int32(result.RowsAffected)}, nil
+	return &MetaResolver{int32(count)}, nil
 }
 
+// Links returns the links matching the term filters, ordered and paginated
+// by the database.
 func (r RootResolver) Links(args LinksQueryArgs) (*[]*LinkResolver, error) {
-	var (
-		links   []model.Link
-		results []model.Link
-	)
-	if err := r.DB.Find(&links).Error; err != nil {
-		return nil, err
-	}
-	if args.And != nil {
-		for _, link := range links {
-			hasAllTerms := true
-			for _, term := range *args.And {
-				if hasAllTerms == false {
-					break
-				}
-				if strings.Contains(link.Description, term) || strings.Contains(link.Url, term) {
-					hasAllTerms = true
-				} else {
-					hasAllTerms = false
-				}
-			}
-			if hasAllTerms == true {
-				results = append(results, link)
-			}
-		}
-	} else if args.Or != nil {
-		for _, link := range links {
-			for _, term := range *args.Or {
-				if strings.Contains(link.Description, term) || strings.Contains(link.Url, term) {
-					results = append(results, link)
-					break
-				}
-			}
+	filter, errs := linkFilter(args.Or, args.And)
+	query := db.LinkQuery{LinkFilter: filter}
+	if args.First != nil {
+		if *args.First < 0 {
+			errs = append(errs, apperrors.Field("first", "can't be negative"))
 		}
-	} else {
-		results = links
+		query.First = int(*args.First)
 	}
-
 	if args.Skip != nil {
-		if args.First != nil && (len(results) > int(*args.First+*args.Skip)) {
-			results = results[int(*args.Skip):int(*args.First+*args.Skip)]
-		} else {
-			results = results[int(*args.Skip):]
+		if *args.Skip < 0 {
+			errs = append(errs, apperrors.Field("skip", "can't be negative"))
 		}
+		query.Skip = int(*args.Skip)
+	}
+	if err := apperrors.Invalid("links", errs); err != nil {
+		return nil, err
+	}
+	if args.First != nil && *args.First == 0 {
+		return &[]*LinkResolver{}, nil
 	}
-
 	if args.OrderBy != nil {
-		if *args.OrderBy == "createdAt_DESC" {
-			sort.Slice(results, func(i, j int) bool {
-				return results[i].CreatedAt.Before(results[j].CreatedAt)
-			})
-		}
+		query.OrderBy = *args.OrderBy
 	}
-	var resolvers []*LinkResolver
-	for _, link := range results {
-		resolvers = append(resolvers, &LinkResolver{r.DB, link})
+	links, err := r.DB.ListLinks(query)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*LinkResolver, 0, len(links))
+	for _, link := range links {
+		resolvers = append(resolvers, &LinkResolver{r.DB, *link})
 	}
 	return &resolvers, nil
 }
 
+type LinksConnectionArgs struct {
+	ConnectionArgs
+	Or  *[]string
+	And *[]string
+}
+
+// LinksConnection returns a page of the links matching the term filters,
+// newest first.
+func (r RootResolver) LinksConnection(args LinksConnectionArgs) (*LinkConnectionResolver, error) {
+	filter, errs := linkFilter(args.Or, args.And)
+	if err := apperrors.Invalid("linksConnection", errs); err != nil {
+		return nil, err
+	}
+	return newLinkConnection(r.DB, filter, args.ConnectionArgs)
+}
+
+// linkFilter returns the filter for the OR and AND term arguments, and an
+// error for every blank term.
+func linkFilter(or, and *[]string) (db.LinkFilter, apperrors.Errors) {
+	var (
+		filter db.LinkFilter
+		errs   apperrors.Errors
+	)
+	if or != nil {
+		filter.Or = *or
+		errs = append(errs, blankTerms("OR", filter.Or)...)
+	}
+	if and != nil {
+		filter.And = *and
+		errs = append(errs, blankTerms("AND", filter.And)...)
+	}
+	return filter, errs
+}
+
+func blankTerms(field string, terms []string) apperrors.Errors {
+	var errs apperrors.Errors
+	for i, term := range terms {
+		if strings.TrimSpace(term) == "" {
+			errs = append(errs, apperrors.WithIndex(apperrors.Field(field, "terms can't be blank"), i))
+		}
+	}
+	return errs
+}
+
 type SignupArgs struct {
 	Email    string
 	Password string
 	Name     string
 }
 
+type UpdateLinkArgs struct {
+	ID          graphql.ID
+	Description *string
+	Url         *string
+	Body        *string
+}
+
+// UpdateLink edits the description, url or body of a link.
+func (r *RootResolver) UpdateLink(ctx context.Context, args UpdateLinkArgs) (*LinkResolver, error) {
+	link, err := r.editableLink(ctx, "updateLink", args.ID)
+	if err != nil {
+		return nil, err
+	}
+	var errs apperrors.Errors
+	if args.Description != nil {
+		link.Description = strings.TrimSpace(*args.Description)
+		errs = append(errs, fieldErrors(checkText("description", link.Description, maxDescriptionLength))...)
+	}
+	var errUrl error
+	if args.Url != nil {
+		link.Url, errUrl = postUrl(args.Url)
+	}
+	if args.Body != nil {
+		link.Body = strings.TrimSpace(*args.Body)
+	}
+	errs = append(errs, checkPost(link.Type, link.Url, link.Body, errUrl)...)
+	if err := apperrors.Invalid("updateLink", errs); err != nil {
+		return nil, err
+	}
+	if err := r.DB.UpdateLink(link); err != nil {
+		return nil, err
+	}
+	// The url's domain can affect the rank.
+	r.refreshRank(link.ID)
+
+	r.publish(topicLinkUpdated, link)
+	linkResolver := &LinkResolver{DB: r.DB, Link: *link}
+	return linkResolver, nil
+}
+
+type DeleteLinkArgs struct {
+	ID graphql.ID
+}
+
+// DeleteLink deletes a link, keeping its votes and comments.
+func (r *RootResolver) DeleteLink(ctx context.Context, args DeleteLinkArgs) (*LinkResolver, error) {
+	link, err := r.editableLink(ctx, "deleteLink", args.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.DB.DeleteLink(link); err != nil {
+		return nil, err
+	}
+	r.publish(topicLinkDeleted, link)
+	linkResolver := &LinkResolver{DB: r.DB, Link: *link}
+	return linkResolver, nil
+}
+
+// editableLink returns the link with the given ID if the current user may edit
+// it: moderators always, posters only within the edit window.
+func (r *RootResolver) editableLink(ctx context.Context, op string, linkID graphql.ID) (*model.Link, error) {
+	principal, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := getUintFromGraphqlId(linkID)
+	if err != nil {
+		return nil, err
+	}
+	link, err := r.DB.GetLinkById(id)
+	if err != nil {
+		return nil, err
+	}
+	if principal.Can(auth.PermissionModerate) {
+		return link, nil
+	}
+	if link.PosterID != principal.UserID {
+		return nil, &auth.PermissionError{Permission: auth.PermissionModerate}
+	}
+	if time.Since(link.CreatedAt) > r.Config.Links.EditWindow {
+		return nil, apperrors.New(apperrors.Forbidden, "%s: links can only be changed within %s of posting", op, r.Config.Links.EditWindow)
+	}
+	return link, nil
+}
+
 type UpvoteArgs struct {
 	LinkID graphql.ID
 }
 
+// Upvote casts an upvote on a link, or turns the user's downvote into one.
 func (r *RootResolver) Upvote(ctx context.Context, args UpvoteArgs) (*VoteResolver, error) {
-	token, ok := ctx.Value("token").(string)
-	if !ok {
-		return &VoteResolver{}, errors.New("post: no key 'token' in context")
+	if err := args.validate("upVote"); err != nil {
+		return nil, err
 	}
-	voter, errVoter := r.DB.GetUserFromToken(token)
+	return r.castVote(ctx, args.LinkID, model.Upvote)
+}
+
+// DownVote casts a downvote on a link, or turns the user's upvote into one.
+// Only users with enough karma may downvote.
+func (r *RootResolver) DownVote(ctx context.Context, args UpvoteArgs) (*VoteResolver, error) {
+	if err := args.validate("downVote"); err != nil {
+		return nil, err
+	}
+	return r.castVote(ctx, args.LinkID, model.Downvote)
+}
+
+func (r *RootResolver) castVote(ctx context.Context, linkID graphql.ID, direction int) (*VoteResolver, error) {
+	voter, errVoter := auth.RequireUser(ctx)
 	if errVoter != nil {
 		return &VoteResolver{}, errVoter
 	}
+	if direction == model.Downvote {
+		karma, err := r.DB.GetUserKarma(voter.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if karma < r.Config.Votes.DownvoteKarma {
+			return nil, apperrors.New(apperrors.Forbidden, "downVote: %d karma is needed to downvote", r.Config.Votes.DownvoteKarma)
+		}
+	}
+	id, err := getUintFromGraphqlId(linkID)
+	if err != nil {
+		return nil, err
+	}
+	link, err := r.DB.GetLinkById(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.applyVote(voter.UserID, link, direction)
+}
+
+// applyVote records a user's vote on a link, changing the direction of any
+// vote they already cast, then reranks the link and publishes the vote.
+func (r *RootResolver) applyVote(voterID uint, link *model.Link, direction int) (*VoteResolver, error) {
+	action := VoteActionCast
+	vote, err := r.DB.GetVoteByUserAndLink(voterID, link.ID)
+	switch {
+	case err == nil && vote.Direction == direction:
+		return nil, db.ErrDuplicateVote
+	case err == nil:
+		if err := r.DB.UpdateVoteDirection(vote, direction); err != nil {
+			return nil, err
+		}
+		action = VoteActionChanged
+	case db.IsNotFound(err):
+		vote = &model.Vote{LinkID: link.ID, UserID: voterID, Direction: direction}
+		if err := r.DB.CreateVote(vote); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	r.refreshRank(link.ID)
+
+	r.publishVote(*vote, action)
+	return &VoteResolver{DB: r.DB, Vote: *vote}, nil
+}
+
+// UnVote retracts the user's vote on a link and returns the retracted vote.
+func (r *RootResolver) UnVote(ctx context.Context, args UpvoteArgs) (*VoteResolver, error) {
+	voter, errVoter := auth.RequireUser(ctx)
+	if errVoter != nil {
+		return nil, errVoter
+	}
+	if err := args.validate("unVote"); err != nil {
+		return nil, err
+	}
+	id, err := getUintFromGraphqlId(args.LinkID)
+	if err != nil {
+		return nil, err
+	}
+	vote, err := r.DB.GetVoteByUserAndLink(voter.UserID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.DB.DeleteVote(vote); err != nil {
+		return nil, err
+	}
+	r.refreshRank(vote.LinkID)
+	r.publishVote(*vote, VoteActionRetracted)
+	return &VoteResolver{DB: r.DB, Vote: *vote}, nil
+}
+
+// refreshRank recomputes a link's rank after something that affects it changed.
+// Failures are only logged; the periodic refresh will catch up.
+func (r *RootResolver) refreshRank(linkID uint) {
+	if err := r.Ranker.RefreshLink(linkID); err != nil {
+		log.Println("ranking: unable to refresh link", linkID, err)
+	}
+}
+
+// publishVote publishes a vote and the score of its link, which it changed.
+func (r *RootResolver) publishVote(vote model.Vote, action string) {
+	r.publish(topicNewVote, voteEventPayload{Action: action, Vote: vote})
+	score, err := r.DB.GetLinkScore(vote.LinkID)
+	if err != nil {
+		log.Println("pubsub: unable to get score of link", vote.LinkID, err)
+		return
+	}
+	r.publish(topicLinkScoreChanged, scoreEventPayload{LinkID: vote.LinkID, Score: score})
+}
+
+type PostCommentArgs struct {
+	LinkID graphql.ID
+	Body   string
+}
+
+// PostComment adds a top-level comment to a link.
+func (r *RootResolver) PostComment(ctx context.Context, args PostCommentArgs) (*CommentResolver, error) {
+	author, errAuthor := auth.RequireUser(ctx)
+	if errAuthor != nil {
+		return nil, errAuthor
+	}
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+	body := strings.TrimSpace(args.Body)
 	id, err := getUintFromGraphqlId(args.LinkID)
 	if err != nil {
 		return nil, err
@@ -327,23 +809,70 @@ func (r *RootResolver) Upvote(ctx context.Context, args UpvoteArgs) (*VoteResolv
 	if err != nil {
 		return nil, err
 	}
-	vote := model.Vote{LinkID: link.ID, UserID: voter.ID}
-	if err := r.DB.CreateVote(&vote); err != nil {
+	comment := model.Comment{
+		Body:     body,
+		AuthorID: author.UserID,
+		LinkID:   link.ID,
+	}
+	if err := r.DB.CreateComment(&comment); err != nil {
 		return nil, err
 	}
-	voteResolver := &VoteResolver{DB: r.DB, Vote: vote}
-	select {
-	case r.NewVoteEvents <- &NewVoteEvent{Vote: voteResolver, EventID: randomID()}:
-		// values are being read from r.Events
-		fmt.Println("r.NewVotes: inserted vote")
-	default:
-		//no subscribers, link not in channel
-		fmt.Println("r.NewVotes: vote created, not inserted")
+	r.refreshRank(comment.LinkID)
+	return &CommentResolver{DB: r.DB, Comment: comment}, nil
+}
+
+type ReplyToCommentArgs struct {
+	CommentID graphql.ID
+	Body      string
+}
+
+// ReplyToComment adds a reply to an existing comment, as long as the reply
+// wouldn't be nested deeper than the configured maximum.
+func (r *RootResolver) ReplyToComment(ctx context.Context, args ReplyToCommentArgs) (*CommentResolver, error) {
+	author, errAuthor := auth.RequireUser(ctx)
+	if errAuthor != nil {
+		return nil, errAuthor
+	}
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+	body := strings.TrimSpace(args.Body)
+	id, err := getUintFromGraphqlId(args.CommentID)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := r.DB.GetCommentById(id)
+	if err != nil {
+		return nil, err
+	}
+	if parent.Depth+1 > r.Config.Comments.MaxDepth {
+		return nil, apperrors.New(apperrors.Validation, "replyToComment: replies can't be nested more than %d levels deep", r.Config.Comments.MaxDepth)
 	}
-	return voteResolver, nil
+	comment := model.Comment{
+		Body:     body,
+		AuthorID: author.UserID,
+		LinkID:   parent.LinkID,
+		ParentID: &parent.ID,
+		Depth:    parent.Depth + 1,
+	}
+	if err := r.DB.CreateComment(&comment); err != nil {
+		return nil, err
+	}
+	r.refreshRank(comment.LinkID)
+	return &CommentResolver{DB: r.DB, Comment: comment}, nil
 }
 
+// Signup creates an account and logs it in. Emails and names are unique,
+// ignoring case.
 func (r *RootResolver) Signup(args SignupArgs) (*AuthResolver, error) {
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+	email, name := strings.TrimSpace(args.Email), strings.TrimSpace(args.Name)
+	if err := r.checkAccountTaken(email, name); err != nil {
+		return nil, err
+	}
+
 	passwordHash, errHash := bcrypt.GenerateFromPassword(
 		[]byte(args.Password),
 		bcrypt.DefaultCost,
@@ -353,27 +882,46 @@ func (r *RootResolver) Signup(args SignupArgs) (*AuthResolver, error) {
 	}
 
 	newUser := model.User{
-		Email:          args.Email,
+		Email:          email,
 		HashedPassword: passwordHash,
-		Name:           args.Name,
+		Name:           name,
 	}
 
-	if err := r.DB.CreateUser(&newUser); err != nil {
+	// The unique indexes catch accounts created since the check above.
+	switch err := r.DB.CreateUser(&newUser); err {
+	case nil:
+	case db.ErrEmailTaken:
+		return nil, apperrors.Conflicting("signup", apperrors.Errors{apperrors.Field("email", "is already taken")})
+	case db.ErrNameTaken:
+		return nil, apperrors.Conflicting("signup", apperrors.Errors{apperrors.Field("name", "is already taken")})
+	default:
 		return nil, err
 	}
 
-	token, errToken := GenerateToken(&newUser)
-
-	if errToken != nil {
-		return nil, errToken
-	}
+	return newAuthResolver(r.DB, &newUser)
+}
 
-	payload := AuthPayload{
-		Token: &token,
-		User:  &newUser,
+// checkAccountTaken reports whether the email or name of a new account is
+// already taken.
+func (r *RootResolver) checkAccountTaken(email, name string) error {
+	var errs apperrors.Errors
+	for _, check := range []struct {
+		field  string
+		lookup func(string) (*model.User, error)
+		value  string
+	}{
+		{"email", r.DB.GetUserByEmail, email},
+		{"name", r.DB.GetUserByName, name},
+	} {
+		_, err := check.lookup(check.value)
+		switch {
+		case err == nil:
+			errs = append(errs, apperrors.Field(check.field, "is already taken"))
+		case !db.IsNotFound(err):
+			return err
+		}
 	}
-
-	return &AuthResolver{r.DB, payload}, nil
+	return apperrors.Conflicting("signup", errs)
 }
 
 type LoginArgs struct {
@@ -381,29 +929,130 @@ type LoginArgs struct {
 	Password string
 }
 
-func (r *RootResolver) Login(args LoginArgs) (*AuthResolver, error) {
+// Login checks the credentials and returns a token for the user. Unknown emails
+// and wrong passwords fail the same way and take the same time, and repeated
+// failures lock out the account and the client IP for a while.
+func (r *RootResolver) Login(ctx context.Context, args LoginArgs) (*AuthResolver, error) {
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+	args.Email = strings.TrimSpace(args.Email)
+	ip := auth.ClientIP(ctx)
+	if err := r.LoginThrottle.Begin(args.Email, ip); err != nil {
+		return nil, err
+	}
 	user, errUser := r.DB.GetUserByEmail(args.Email)
 	if errUser != nil {
-		return nil, errUser
+		if !db.IsNotFound(errUser) {
+			r.LoginThrottle.Release(args.Email, ip)
+			return nil, errUser
+		}
+		user = nil
+	}
+	if !auth.VerifyPassword(user, args.Password) {
+		r.LoginThrottle.Failed(args.Email, ip)
+		return nil, auth.ErrInvalidCredentials
 	}
-	model.ComparePasswordHash(user.HashedPassword, []byte(args.Password))
+	r.LoginThrottle.Succeeded(args.Email, ip)
 
-	token, errToken := GenerateToken(user)
+	return newAuthResolver(r.DB, user)
+}
+
+type RefreshTokenArgs struct {
+	Token string
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. The old refresh token can't be used again.
+func (r *RootResolver) RefreshToken(args RefreshTokenArgs) (*AuthResolver, error) {
+	user, refreshToken, err := r.DB.RotateRefreshToken(args.Token)
+	if err != nil {
+		return nil, err
+	}
+	token, errToken := r.DB.GenerateToken(user)
 	if errToken != nil {
 		return nil, errToken
 	}
 	payload := AuthPayload{
-		Token: &token,
-		User:  user,
+		Token:        &token,
+		RefreshToken: &refreshToken,
+		User:         user,
 	}
 	return &AuthResolver{r.DB, payload}, nil
 }
 
+type SetUserRoleArgs struct {
+	UserID graphql.ID
+	Role   string
+}
+
+// SetUserRole changes the role of a user. Only admins may do this, and not to
+// themselves, so that there is always an admin left.
+func (r *RootResolver) SetUserRole(ctx context.Context, args SetUserRoleArgs) (*UserResolver, error) {
+	principal, err := auth.Require(ctx, auth.PermissionManageUsers)
+	if err != nil {
+		return nil, err
+	}
+	role := strings.ToLower(args.Role)
+	if !auth.ValidRole(role) {
+		return nil, apperrors.Invalid("setUserRole", apperrors.Errors{apperrors.Field("role", "unknown role %q", args.Role)})
+	}
+	id, err := getUintFromGraphqlId(args.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if id == principal.UserID {
+		return nil, apperrors.New(apperrors.Forbidden, "setUserRole: admins can't change their own role")
+	}
+	user, err := r.DB.GetUserById(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.DB.SetUserRole(user, role); err != nil {
+		return nil, err
+	}
+	return &UserResolver{DB: r.DB, User: *user}, nil
+}
+
+type LogoutArgs struct {
+	RefreshToken *string
+}
+
+// Logout revokes the access token the request was made with and, if given,
+// the refresh token of the same session.
+func (r *RootResolver) Logout(ctx context.Context, args LogoutArgs) (bool, error) {
+	principal, err := auth.RequireUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := r.DB.RevokeToken(principal.TokenID, principal.TokenExpiresAt); err != nil {
+		return false, err
+	}
+	if args.RefreshToken != nil {
+		if err := r.DB.RevokeRefreshToken(principal.UserID, *args.RefreshToken); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// LogoutAllSessions revokes every access and refresh token of the current user.
+func (r *RootResolver) LogoutAllSessions(ctx context.Context) (bool, error) {
+	principal, err := auth.RequireUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := r.DB.RevokeAllSessions(principal.UserID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 //Helpers
 func getUintFromGraphqlId(gqlid graphql.ID) (uint, error) {
 	id, err := strconv.ParseUint(string(gqlid), 10, 32)
 	if err != nil {
-		return 0, err
+		return 0, apperrors.New(apperrors.Validation, "invalid ID %q", gqlid)
 	}
 	return uint(id), nil
 }
@@ -0,0 +1,139 @@
+package resolvers
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/leggettc18/hackernews-clone-api/db"
+	apperrors "github.com/leggettc18/hackernews-clone-api/errors"
+)
+
+// searchCursorKind is the kind of search cursors. Search results have no
+// stable order to key on, so their cursors hold the result's position instead.
+const searchCursorKind = "Search"
+
+type SearchArgs struct {
+	Query string
+	First *int32
+	After *graphql.ID
+}
+
+// Search returns the links and comments matching a full-text query, most
+// relevant first.
+func (r RootResolver) Search(args SearchArgs) (*SearchConnectionResolver, error) {
+	var errs apperrors.Errors
+	if strings.TrimSpace(args.Query) == "" {
+		errs = append(errs, apperrors.Field("query", "can't be empty"))
+	}
+	limit := defaultPageSize
+	if args.First != nil {
+		if *args.First < 0 || *args.First > maxPageSize {
+			errs = append(errs, apperrors.Field("first", "must be between 0 and %d", maxPageSize))
+		}
+		limit = int(*args.First)
+	}
+	var offset uint
+	if args.After != nil {
+		var err error
+		if offset, err = decodeCursor(searchCursorKind, *args.After); err != nil {
+			errs = append(errs, apperrors.Field("after", "%s", err))
+		}
+	}
+	if err := apperrors.Invalid("search", errs); err != nil {
+		return nil, err
+	}
+	results, more, err := r.DB.Search(args.Query, limit, int(offset))
+	if err == db.ErrNoSearchTerms {
+		return nil, apperrors.Invalid("search", apperrors.Errors{apperrors.Field("query", "has no words to search for")})
+	}
+	if err != nil {
+		return nil, err
+	}
+	var start, end *graphql.ID
+	if len(results) > 0 {
+		first, last := encodeCursor(searchCursorKind, offset+1), encodeCursor(searchCursorKind, offset+uint(len(results)))
+		start, end = &first, &last
+	}
+	return &SearchConnectionResolver{
+		DB:      r.DB,
+		offset:  offset,
+		results: results,
+		pageInfo: &PageInfoResolver{
+			hasNextPage:     more,
+			hasPreviousPage: offset > 0,
+			startCursor:     start,
+			endCursor:       end,
+		},
+	}, nil
+}
+
+type SearchConnectionResolver struct {
+	DB       *db.DB
+	offset   uint
+	results  []db.SearchResult
+	pageInfo *PageInfoResolver
+}
+
+func (r *SearchConnectionResolver) Edges() []*SearchEdgeResolver {
+	edges := make([]*SearchEdgeResolver, 0, len(r.results))
+	for i, result := range r.results {
+		edges = append(edges, &SearchEdgeResolver{r.DB, r.offset + uint(i) + 1, result})
+	}
+	return edges
+}
+
+func (r *SearchConnectionResolver) PageInfo() *PageInfoResolver {
+	return r.pageInfo
+}
+
+type SearchEdgeResolver struct {
+	DB       *db.DB
+	position uint
+	Result   db.SearchResult
+}
+
+func (r *SearchEdgeResolver) Cursor() graphql.ID {
+	return encodeCursor(searchCursorKind, r.position)
+}
+
+func (r *SearchEdgeResolver) Node() *SearchResultResolver {
+	return &SearchResultResolver{r.DB, r.Result}
+}
+
+type SearchResultResolver struct {
+	DB     *db.DB
+	Result db.SearchResult
+}
+
+func (r *SearchResultResolver) Link(ctx context.Context) (*LinkResolver, error) {
+	link, err := loadLink(ctx, r.DB, r.Result.LinkID)
+	if err != nil {
+		return nil, err
+	}
+	return &LinkResolver{r.DB, *link}, nil
+}
+
+func (r *SearchResultResolver) Comment() (*CommentResolver, error) {
+	if r.Result.Kind != db.SearchKindComment {
+		return nil, nil
+	}
+	comment, err := r.DB.GetCommentById(r.Result.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &CommentResolver{DB: r.DB, Comment: *comment}, nil
+}
+
+// Snippet returns the matching text as HTML, with the matched terms in <mark> tags.
+func (r *SearchResultResolver) Snippet() string {
+	return strings.NewReplacer(
+		db.HighlightStart, "<mark>",
+		db.HighlightEnd, "</mark>",
+	).Replace(html.EscapeString(r.Result.Snippet))
+}
+
+func (r *SearchResultResolver) Rank() float64 {
+	return r.Result.Rank
+}
@@ -1,15 +1,21 @@
 package resolvers
 
 import (
+	"context"
 	"fmt"
 	"github.com/graph-gophers/graphql-go"
+	"github.com/leggettc18/hackernews-clone-api/auth"
 	"github.com/leggettc18/hackernews-clone-api/db"
+	apperrors "github.com/leggettc18/hackernews-clone-api/errors"
 	"github.com/leggettc18/hackernews-clone-api/model"
+	"strings"
 )
 
 type UserResolver struct {
 	DB   *db.DB
 	User model.User
+	// self is set when the user is known to be the one making the request.
+	self bool
 }
 
 func (r *UserResolver) ID() graphql.ID {
@@ -20,28 +26,137 @@ func (r *UserResolver) Name() string {
 	return r.User.Name
 }
 
-func (r *UserResolver) Email() string {
-	return r.User.Email
+// Email is only visible to the user themselves and to admins.
+func (r *UserResolver) Email(ctx context.Context) *string {
+	if r.self {
+		return &r.User.Email
+	}
+	principal, ok := auth.FromContext(ctx)
+	if ok && (principal.UserID == r.User.ID || principal.Can(auth.PermissionViewEmails)) {
+		return &r.User.Email
+	}
+	return nil
 }
 
-func (r *UserResolver) Links() (*[]*LinkResolver, error) {
-	resolvers := make([]*LinkResolver, len(r.User.Links))
-	for _, link := range r.User.Links {
-		if link.PosterID == r.User.ID {
-			resolver := LinkResolver{r.DB, link}
-			resolvers = append(resolvers, &resolver)
-		}
+// Role is the user's role as a Role enum value.
+func (r *UserResolver) Role() string {
+	role := r.User.Role
+	if role == "" {
+		role = model.RoleUser
+	}
+	return strings.ToUpper(role)
+}
+
+type UserLinksArgs struct {
+	First   *int32
+	Skip    *int32
+	OrderBy *string
+}
+
+// Links returns the links the user posted, newest first unless ordered otherwise.
+func (r *UserResolver) Links(args UserLinksArgs) (*[]*LinkResolver, error) {
+	first, skip, err := userListPage("links", args.First, args.Skip)
+	if err != nil {
+		return nil, err
+	}
+	if first == 0 {
+		return &[]*LinkResolver{}, nil
+	}
+	query := db.LinkQuery{
+		LinkFilter: db.LinkFilter{PosterID: r.User.ID},
+		OrderBy:    db.OrderCreatedAtDesc,
+		First:      first,
+		Skip:       skip,
+	}
+	if args.OrderBy != nil {
+		query.OrderBy = *args.OrderBy
+	}
+	links, err := r.DB.ListLinks(query)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*LinkResolver, 0, len(links))
+	for _, link := range links {
+		resolvers = append(resolvers, &LinkResolver{r.DB, *link})
 	}
 	return &resolvers, nil
 }
 
-func (r *UserResolver) Votes() (*[]*VoteResolver, error) {
-	resolvers := make([]*VoteResolver, len(r.User.Votes))
-	for _, vote := range r.User.Votes {
-		if vote.UserID == r.User.ID {
-			resolver := VoteResolver{r.DB, vote}
-			resolvers = append(resolvers, &resolver)
+type UserVotesArgs struct {
+	First     *int32
+	Skip      *int32
+	Direction *int32
+}
+
+// Votes returns the votes the user cast, newest first, optionally only those
+// in one direction.
+func (r *UserResolver) Votes(args UserVotesArgs) (*[]*VoteResolver, error) {
+	first, skip, err := userListPage("votes", args.First, args.Skip)
+	if err != nil {
+		return nil, err
+	}
+	filter := db.VoteFilter{UserID: r.User.ID}
+	if args.Direction != nil {
+		if *args.Direction != model.Upvote && *args.Direction != model.Downvote {
+			return nil, apperrors.Invalid("votes", apperrors.Errors{apperrors.Field("direction", "must be 1 or -1")})
 		}
+		filter.Direction = int(*args.Direction)
+	}
+	if first == 0 {
+		return &[]*VoteResolver{}, nil
+	}
+	votes, err := r.DB.ListVotes(filter, first, skip)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*VoteResolver, 0, len(votes))
+	for _, vote := range votes {
+		resolvers = append(resolvers, &VoteResolver{r.DB, *vote})
 	}
 	return &resolvers, nil
 }
+
+// userListPage checks the pagination arguments of a user's list field and
+// returns the page size and offset.
+func userListPage(field string, first, skip *int32) (int, int, error) {
+	var (
+		size, offset = defaultPageSize, 0
+		errs         apperrors.Errors
+	)
+	if first != nil {
+		if *first < 0 || *first > maxPageSize {
+			errs = append(errs, apperrors.Field("first", "must be between 0 and %d", maxPageSize))
+		}
+		size = int(*first)
+	}
+	if skip != nil {
+		if *skip < 0 {
+			errs = append(errs, apperrors.Field("skip", "can't be negative"))
+		}
+		offset = int(*skip)
+	}
+	return size, offset, apperrors.Invalid(field, errs)
+}
+
+// LinkCount is the number of links the user posted.
+func (r *UserResolver) LinkCount() (int32, error) {
+	count, err := r.DB.CountLinks(db.LinkFilter{PosterID: r.User.ID})
+	return int32(count), err
+}
+
+// VoteCount is the number of votes the user cast.
+func (r *UserResolver) VoteCount() (int32, error) {
+	count, err := r.DB.CountVotes(db.VoteFilter{UserID: r.User.ID})
+	return int32(count), err
+}
+
+// Karma is the sum of the votes other users cast on the user's links.
+func (r *UserResolver) Karma() (int32, error) {
+	karma, err := r.DB.GetUserKarma(r.User.ID)
+	return int32(karma), err
+}
+
+// LinksConnection returns a page of the links the user posted, newest first.
+func (r *UserResolver) LinksConnection(args ConnectionArgs) (*LinkConnectionResolver, error) {
+	return newLinkConnection(r.DB, db.LinkFilter{PosterID: r.User.ID}, args)
+}
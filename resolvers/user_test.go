@@ -0,0 +1,39 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package resolvers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/leggettc18/hackernews-clone-api/loader"
+	"github.com/leggettc18/hackernews-clone-api/model"
+)
+
+func TestUserQuery(t *testing.T) {
+	r := newTestRoot(t)
+	admin := &model.User{Email: "admin@example.com", Name: "admin"}
+	if err := r.DB.CreateUser(admin); err != nil {
+		t.Fatal(err)
+	}
+
+	contexts := map[string]context.Context{
+		"without loaders": context.Background(),
+		"with loaders":    loader.Attach(context.Background(), r.DB),
+	}
+	for name, ctx := range contexts {
+		user, err := r.User(ctx, UserQueryArgs{ID: graphql.ID(strconv.Itoa(int(admin.ID)))})
+		if err != nil || user == nil || user.Name() != "admin" {
+			t.Errorf("%s: existing user: got %v, %v", name, user, err)
+		}
+		// ID 0 used to match the first user, as gorm drops zero conditions.
+		for _, id := range []graphql.ID{"0", graphql.ID(strconv.Itoa(int(admin.ID) + 1))} {
+			if user, err := r.User(ctx, UserQueryArgs{ID: id}); err != nil || user != nil {
+				t.Errorf("%s: user %s: got %v, %v, want not found", name, id, user, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package resolvers
+
+import (
+	"net/mail"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/graph-gophers/graphql-go"
+	apperrors "github.com/leggettc18/hackernews-clone-api/errors"
+)
+
+// Limits on what users can enter.
+const (
+	maxEmailLength    = 254
+	minPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes.
+	maxPasswordLength    = 72
+	minNameLength        = 2
+	maxNameLength        = 32
+	maxDescriptionLength = 255
+	maxCommentLength     = 10000
+	// Subscription filters are checked against every event, so keep them short.
+	maxSubscriptionTerms = 20
+	maxSubscriptionLinks = 100
+)
+
+// The validate methods check arguments before anything is looked up or
+// changed, reporting every invalid field at once.
+
+func (args SignupArgs) validate() error {
+	return apperrors.Invalid("signup", fieldErrors(
+		checkEmail("email", args.Email),
+		checkPassword("password", args.Password, args.Email, args.Name),
+		checkName("name", args.Name),
+	))
+}
+
+func (args LoginArgs) validate() error {
+	return apperrors.Invalid("login", fieldErrors(
+		checkRequired("email", args.Email, maxEmailLength),
+		checkRequired("password", args.Password, maxPasswordLength),
+	))
+}
+
+func (args UpvoteArgs) validate(op string) error {
+	return apperrors.Invalid(op, fieldErrors(
+		checkID("linkId", args.LinkID),
+	))
+}
+
+func (args PostCommentArgs) validate() error {
+	return apperrors.Invalid("postComment", fieldErrors(
+		checkID("linkId", args.LinkID),
+		checkText("body", args.Body, maxCommentLength),
+	))
+}
+
+func (args ReplyToCommentArgs) validate() error {
+	return apperrors.Invalid("replyToComment", fieldErrors(
+		checkID("commentId", args.CommentID),
+		checkText("body", args.Body, maxCommentLength),
+	))
+}
+
+func (args NewLinkSubscriptionArgs) validate() error {
+	var errs apperrors.Errors
+	if args.Terms != nil {
+		if len(*args.Terms) > maxSubscriptionTerms {
+			errs = append(errs, apperrors.Field("terms", "can't list more than %d terms", maxSubscriptionTerms))
+		}
+		for i, term := range *args.Terms {
+			if strings.TrimSpace(term) == "" {
+				errs = append(errs, apperrors.WithIndex(apperrors.Field("terms", "can't be blank"), i))
+			}
+		}
+	}
+	return apperrors.Invalid("newLink", errs)
+}
+
+func (args NewVoteSubscriptionArgs) validate() error {
+	if args.LinkID == nil {
+		return nil
+	}
+	return apperrors.Invalid("newVote", fieldErrors(checkID("linkId", *args.LinkID)))
+}
+
+func (args LinkScoreChangedArgs) validate() error {
+	var errs apperrors.Errors
+	if args.LinkIDs != nil {
+		if len(*args.LinkIDs) > maxSubscriptionLinks {
+			errs = append(errs, apperrors.Field("linkIds", "can't list more than %d links", maxSubscriptionLinks))
+		}
+		for i, id := range *args.LinkIDs {
+			if err := checkID("linkIds", id); err != nil {
+				errs = append(errs, apperrors.WithIndex(err, i))
+			}
+		}
+	}
+	return apperrors.Invalid("linkScoreChanged", errs)
+}
+
+// fieldErrors collects the checks that failed.
+func fieldErrors(checks ...error) apperrors.Errors {
+	var errs apperrors.Errors
+	for _, err := range checks {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// checkEmail checks that email is a plain address such as name@example.com.
+func checkEmail(field, email string) error {
+	email = strings.TrimSpace(email)
+	if err := checkRequired(field, email, maxEmailLength); err != nil {
+		return err
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return apperrors.Field(field, "isn't a valid email address")
+	}
+	at := strings.LastIndex(email, "@")
+	if domain := email[at+1:]; !strings.Contains(strings.Trim(domain, "."), ".") {
+		return apperrors.Field(field, "isn't a valid email address")
+	}
+	return nil
+}
+
+// checkPassword checks that password is long enough, mixes at least two kinds
+// of characters and doesn't contain the user's name or email.
+func checkPassword(field, password, email, name string) error {
+	switch {
+	case utf8.RuneCountInString(password) < minPasswordLength:
+		return apperrors.Field(field, "must be at least %d characters", minPasswordLength)
+	case len(password) > maxPasswordLength:
+		return apperrors.Field(field, "can't be longer than %d bytes", maxPasswordLength)
+	case characterClasses(password) < 2:
+		return apperrors.Field(field, "must mix letters, digits or symbols")
+	}
+	lower := strings.ToLower(password)
+	for _, personal := range []string{strings.TrimSpace(name), localPart(email)} {
+		if len(personal) >= 3 && strings.Contains(lower, strings.ToLower(personal)) {
+			return apperrors.Field(field, "can't contain your name or email")
+		}
+	}
+	return nil
+}
+
+// characterClasses counts which of lower case letters, upper case letters,
+// digits and other characters s contains.
+func characterClasses(s string) int {
+	var lower, upper, digit, other int
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+func localPart(email string) string {
+	email = strings.TrimSpace(email)
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		return email[:at]
+	}
+	return email
+}
+
+// checkName checks a display name: letters, digits, '_', '-' and '.' only.
+func checkName(field, name string) error {
+	name = strings.TrimSpace(name)
+	length := utf8.RuneCountInString(name)
+	switch {
+	case length < minNameLength:
+		return apperrors.Field(field, "must be at least %d characters", minNameLength)
+	case length > maxNameLength:
+		return apperrors.Field(field, "can't be longer than %d characters", maxNameLength)
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' && r != '.' {
+			return apperrors.Field(field, "can only contain letters, digits, '_', '-' and '.'")
+		}
+	}
+	return nil
+}
+
+// checkText checks that text isn't blank or longer than max characters.
+func checkText(field, text string, max int) error {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "":
+		return apperrors.Field(field, "can't be empty")
+	case utf8.RuneCountInString(text) > max:
+		return apperrors.Field(field, "can't be longer than %d characters", max)
+	}
+	return nil
+}
+
+// checkRequired checks that value is given and no longer than max bytes.
+func checkRequired(field, value string, max int) error {
+	switch {
+	case value == "":
+		return apperrors.Field(field, "can't be empty")
+	case len(value) > max:
+		return apperrors.Field(field, "can't be longer than %d bytes", max)
+	}
+	return nil
+}
+
+// checkID checks that id is one of the numeric IDs the API hands out, which
+// start at 1.
+func checkID(field string, id graphql.ID) error {
+	if n, err := strconv.ParseUint(string(id), 10, 32); err != nil || n == 0 {
+		return apperrors.Field(field, "isn't a valid ID")
+	}
+	return nil
+}
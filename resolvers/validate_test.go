@@ -0,0 +1,270 @@
+package resolvers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/graph-gophers/graphql-go"
+	apperrors "github.com/leggettc18/hackernews-clone-api/errors"
+)
+
+// errString returns the message of err, or "" if it is nil.
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestCheckID(t *testing.T) {
+	tests := []struct {
+		id   graphql.ID
+		want string
+	}{
+		{"1", ""},
+		{"4294967295", ""},
+		{"0", "linkId: isn't a valid ID"},
+		{"", "linkId: isn't a valid ID"},
+		{"-1", "linkId: isn't a valid ID"},
+		{"+1", "linkId: isn't a valid ID"},
+		{"1.0", "linkId: isn't a valid ID"},
+		{" 1", "linkId: isn't a valid ID"},
+		{"abc", "linkId: isn't a valid ID"},
+		{"4294967296", "linkId: isn't a valid ID"},
+	}
+	for _, test := range tests {
+		if got := errString(checkID("linkId", test.id)); got != test.want {
+			t.Errorf("checkID(%q) = %q, want %q", test.id, got, test.want)
+		}
+	}
+}
+
+func TestCheckEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"alice@example.com", ""},
+		{" alice@example.com ", ""},
+		{"", "email: can't be empty"},
+		{strings.Repeat("a", 243) + "@example.com", "email: can't be longer than 254 bytes"},
+		{"alice", "email: isn't a valid email address"},
+		{"alice@localhost", "email: isn't a valid email address"},
+		{"alice@example.", "email: isn't a valid email address"},
+		{"Alice <alice@example.com>", "email: isn't a valid email address"},
+		{"alice@example.com, bob@example.com", "email: isn't a valid email address"},
+	}
+	for _, test := range tests {
+		if got := errString(checkEmail("email", test.email)); got != test.want {
+			t.Errorf("checkEmail(%q) = %q, want %q", test.email, got, test.want)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"correct horse 9", ""},
+		{"Abcdefgh", ""},
+		{"Abcdefg", "password: must be at least 8 characters"},
+		{"ÄÖÜäöüß1", ""},
+		{strings.Repeat("a1", 36), ""},
+		{strings.Repeat("a1", 36) + "a", "password: can't be longer than 72 bytes"},
+		{"abcdefgh", "password: must mix letters, digits or symbols"},
+		{"12345678", "password: must mix letters, digits or symbols"},
+		{"my-Alice-password", "password: can't contain your name or email"},
+		{"xALICE.Wx9", "password: can't contain your name or email"},
+		{"al1ce-w-pw", ""},
+	}
+	for _, test := range tests {
+		got := errString(checkPassword("password", test.password, "alice.w@example.com", "alice"))
+		if got != test.want {
+			t.Errorf("checkPassword(%q) = %q, want %q", test.password, got, test.want)
+		}
+	}
+
+	// Short names and local parts would rule out too many passwords.
+	if err := checkPassword("password", "Bo-Al-12345", "al@example.com", "bo"); err != nil {
+		t.Errorf("got %v for a short name, want nil", err)
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"alice", ""},
+		{"al", ""},
+		{"a.l_i-c3", ""},
+		{"Zoë", ""},
+		{" alice ", ""},
+		{"a", "name: must be at least 2 characters"},
+		{" a ", "name: must be at least 2 characters"},
+		{strings.Repeat("a", 32), ""},
+		{strings.Repeat("a", 33), "name: can't be longer than 32 characters"},
+		{strings.Repeat("ä", 32), ""},
+		{"alice w", "name: can only contain letters, digits, '_', '-' and '.'"},
+		{"alice@home", "name: can only contain letters, digits, '_', '-' and '.'"},
+	}
+	for _, test := range tests {
+		if got := errString(checkName("name", test.name)); got != test.want {
+			t.Errorf("checkName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCheckText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"hello", ""},
+		{"", "body: can't be empty"},
+		{" \n\t", "body: can't be empty"},
+		{"12345", ""},
+		{"123456", "body: can't be longer than 5 characters"},
+		{"ääääá", ""},
+		{"  12345  ", ""},
+	}
+	for _, test := range tests {
+		if got := errString(checkText("body", test.text, 5)); got != test.want {
+			t.Errorf("checkText(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestCheckRequired(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"abc", ""},
+		{" ", ""},
+		{"", "email: can't be empty"},
+		{"abcde", ""},
+		{"abcdef", "email: can't be longer than 5 bytes"},
+		{"ää", ""},
+		{"äää", "email: can't be longer than 5 bytes"},
+	}
+	for _, test := range tests {
+		if got := errString(checkRequired("email", test.value, 5)); got != test.want {
+			t.Errorf("checkRequired(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestValidateArgs(t *testing.T) {
+	terms := func(terms ...string) *[]string { return &terms }
+	ids := func(ids ...graphql.ID) *[]graphql.ID { return &ids }
+	id := func(id graphql.ID) *graphql.ID { return &id }
+	tests := []struct {
+		name    string
+		err     error
+		message string
+		fields  []string
+	}{
+		{
+			"valid signup",
+			SignupArgs{Email: "alice@example.com", Password: "correct horse 9", Name: "alice"}.validate(),
+			"", nil,
+		},
+		{
+			"every signup field",
+			SignupArgs{Email: "alice", Password: "short", Name: "a"}.validate(),
+			"signup: 3 errors: email: isn't a valid email address; password: must be at least 8 characters; name: must be at least 2 characters",
+			[]string{"email: isn't a valid email address", "password: must be at least 8 characters", "name: must be at least 2 characters"},
+		},
+		{
+			"one signup field",
+			SignupArgs{Email: "alice@example.com", Password: "correct horse 9", Name: "a b"}.validate(),
+			"signup: name: can only contain letters, digits, '_', '-' and '.'",
+			[]string{"name: can only contain letters, digits, '_', '-' and '.'"},
+		},
+		{
+			"empty login",
+			LoginArgs{}.validate(),
+			"login: 2 errors: email: can't be empty; password: can't be empty",
+			[]string{"email: can't be empty", "password: can't be empty"},
+		},
+		{
+			"login doesn't apply the signup rules",
+			LoginArgs{Email: "alice", Password: "a"}.validate(),
+			"", nil,
+		},
+		{
+			"upvote",
+			UpvoteArgs{LinkID: "0"}.validate("upvote"),
+			"upvote: linkId: isn't a valid ID",
+			[]string{"linkId: isn't a valid ID"},
+		},
+		{
+			"comment",
+			PostCommentArgs{LinkID: "x", Body: " "}.validate(),
+			"postComment: 2 errors: linkId: isn't a valid ID; body: can't be empty",
+			[]string{"linkId: isn't a valid ID", "body: can't be empty"},
+		},
+		{
+			"reply",
+			ReplyToCommentArgs{CommentID: "1", Body: strings.Repeat("a", maxCommentLength+1)}.validate(),
+			"replyToComment: body: can't be longer than 10000 characters",
+			[]string{"body: can't be longer than 10000 characters"},
+		},
+		{
+			"no link filter",
+			NewLinkSubscriptionArgs{}.validate(),
+			"", nil,
+		},
+		{
+			"blank terms",
+			NewLinkSubscriptionArgs{Terms: terms("go", " ", "")}.validate(),
+			"newLink: 2 errors: [1]: terms: can't be blank; [2]: terms: can't be blank",
+			[]string{"[1]: terms: can't be blank", "[2]: terms: can't be blank"},
+		},
+		{
+			"too many terms",
+			NewLinkSubscriptionArgs{Terms: terms(strings.Split(strings.Repeat("go,", maxSubscriptionTerms), ",")...)}.validate(),
+			"newLink: 2 errors: terms: can't list more than 20 terms; [20]: terms: can't be blank",
+			[]string{"terms: can't list more than 20 terms", "[20]: terms: can't be blank"},
+		},
+		{
+			"any link's votes",
+			NewVoteSubscriptionArgs{}.validate(),
+			"", nil,
+		},
+		{
+			"one link's votes",
+			NewVoteSubscriptionArgs{LinkID: id("0")}.validate(),
+			"newVote: linkId: isn't a valid ID",
+			[]string{"linkId: isn't a valid ID"},
+		},
+		{
+			"score link IDs",
+			LinkScoreChangedArgs{LinkIDs: ids("1", "0", "2", "b")}.validate(),
+			"linkScoreChanged: 2 errors: [1]: linkIds: isn't a valid ID; [3]: linkIds: isn't a valid ID",
+			[]string{"[1]: linkIds: isn't a valid ID", "[3]: linkIds: isn't a valid ID"},
+		},
+	}
+	for _, test := range tests {
+		if got := errString(test.err); got != test.message {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.message)
+		}
+		if test.err == nil {
+			continue
+		}
+		appErr, ok := test.err.(*apperrors.Error)
+		if !ok || appErr.Code != apperrors.Validation {
+			t.Errorf("%s: got %#v, want a validation error", test.name, test.err)
+			continue
+		}
+		var fields []string
+		for _, field := range appErr.Fields {
+			fields = append(fields, field.Error())
+		}
+		if strings.Join(fields, "\n") != strings.Join(test.fields, "\n") {
+			t.Errorf("%s: got fields %q, want %q", test.name, fields, test.fields)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package resolvers
 
 import (
+	"context"
 	"fmt"
 	"github.com/graph-gophers/graphql-go"
 	"github.com/leggettc18/hackernews-clone-api/db"
@@ -16,16 +17,21 @@ func (r *VoteResolver) ID() graphql.ID {
 	return graphql.ID(fmt.Sprint(r.Vote.ID))
 }
 
-func (r *VoteResolver) User() (*UserResolver, error) {
-	user, err := r.DB.GetUserById(r.Vote.UserID)
+// Direction is 1 for an upvote and -1 for a downvote.
+func (r *VoteResolver) Direction() int32 {
+	return int32(r.Vote.Direction)
+}
+
+func (r *VoteResolver) User(ctx context.Context) (*UserResolver, error) {
+	user, err := loadUser(ctx, r.DB, r.Vote.UserID)
 	if err != nil {
 		return nil, err
 	}
-	return &UserResolver{r.DB, *user}, nil
+	return &UserResolver{DB: r.DB, User: *user}, nil
 }
 
-func (r *VoteResolver) Link() (*LinkResolver, error) {
-	link, err := r.DB.GetLinkById(r.Vote.LinkID)
+func (r *VoteResolver) Link(ctx context.Context) (*LinkResolver, error) {
+	link, err := loadLink(ctx, r.DB, r.Vote.LinkID)
 	if err != nil {
 		return nil, err
 	}
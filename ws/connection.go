@@ -0,0 +1,311 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leggettc18/hackernews-clone-api/auth"
+)
+
+// Message types of the graphql-ws protocol.
+// https://github.com/apollographql/subscriptions-transport-ws/blob/v0.9.4/PROTOCOL.md
+const (
+	typeConnectionInit      = "connection_init"
+	typeConnectionAck       = "connection_ack"
+	typeConnectionError     = "connection_error"
+	typeConnectionKeepAlive = "ka"
+	typeConnectionTerminate = "connection_terminate"
+	typeStart               = "start"
+	typeData                = "data"
+	typeError               = "error"
+	typeComplete            = "complete"
+	typeStop                = "stop"
+)
+
+// Close codes sent when the server ends a connection.
+const (
+	closeInitTimeout  = 4408
+	closeUnauthorized = 4401
+	closeBadRequest   = 4400
+)
+
+var errTokenExpired = errors.New("access token expired")
+
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type startPayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// connection is one client's websocket connection.
+type connection struct {
+	handler *Handler
+	ws      *websocket.Conn
+	// token is the access token the connection was authenticated with, if
+	// any. It is checked again before every operation.
+	token string
+
+	writeMu sync.Mutex
+
+	opsMu sync.Mutex
+	ops   map[string]context.CancelFunc
+}
+
+func newConnection(handler *Handler, ws *websocket.Conn, token string) *connection {
+	return &connection{
+		handler: handler,
+		ws:      ws,
+		token:   token,
+		ops:     map[string]context.CancelFunc{},
+	}
+}
+
+// serve runs the connection until either side closes it.
+func (c *connection) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer c.ws.Close()
+	c.ws.SetReadLimit(readLimit)
+
+	ctx, code, err := c.init(ctx)
+	if err != nil {
+		c.send("", typeConnectionError, errorPayload(err))
+		c.close(code, err.Error())
+		return
+	}
+	c.send("", typeConnectionAck, nil)
+
+	// Connections can't outlive the token they were authenticated with.
+	// Clients are expected to reconnect with a fresh one.
+	if principal, ok := auth.FromContext(ctx); ok && !principal.TokenExpiresAt.IsZero() {
+		expiry := time.AfterFunc(time.Until(principal.TokenExpiresAt), func() {
+			c.send("", typeConnectionError, errorPayload(errTokenExpired))
+			c.close(closeUnauthorized, errTokenExpired.Error())
+		})
+		defer expiry.Stop()
+	}
+
+	go c.keepAlive(ctx)
+	c.readLoop(ctx)
+}
+
+// init waits for the client's connection_init message and returns ctx
+// carrying the principal its token was issued to. Connections without a token
+// stay anonymous, or keep the principal of the upgrade request's
+// Authorization header.
+func (c *connection) init(ctx context.Context) (context.Context, int, error) {
+	c.ws.SetReadDeadline(time.Now().Add(initTimeout))
+	var msg message
+	if err := c.ws.ReadJSON(&msg); err != nil {
+		return ctx, closeInitTimeout, errors.New("connection_init not received")
+	}
+	c.ws.SetReadDeadline(time.Time{})
+	if msg.Type != typeConnectionInit {
+		return ctx, closeBadRequest, fmt.Errorf("expected %s, got %s", typeConnectionInit, msg.Type)
+	}
+
+	token, err := initToken(msg.Payload)
+	if err != nil {
+		return ctx, closeBadRequest, err
+	}
+	if token == "" {
+		return ctx, 0, nil
+	}
+	principal, err := c.handler.Authenticator.Authenticate(token)
+	if err != nil {
+		return ctx, closeUnauthorized, err
+	}
+	c.token = token
+	return auth.WithPrincipal(ctx, principal), 0, nil
+}
+
+// initToken returns the access token from a connection_init payload. Clients
+// differ in where they put it, so authToken, token and Authorization are all
+// accepted, with or without the Bearer scheme.
+func initToken(payload json.RawMessage) (string, error) {
+	if len(payload) == 0 || string(payload) == "null" {
+		return "", nil
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal(payload, &params); err != nil {
+		return "", fmt.Errorf("invalid payload for type: %s", typeConnectionInit)
+	}
+	for _, key := range []string{"authToken", "token", "Authorization", "authorization"} {
+		value, ok := params[key].(string)
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 2 && strings.EqualFold(fields[0], "Bearer") {
+			return fields[1], nil
+		}
+		return strings.TrimSpace(value), nil
+	}
+	return "", nil
+}
+
+func (c *connection) readLoop(ctx context.Context) {
+	defer c.stopAll()
+	for {
+		var msg message
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case typeStart:
+			// Tokens revoked since the connection was made, by logging out,
+			// end it before anything else runs. Operations already running
+			// continue until the token expires.
+			if err := c.reauthenticate(); err != nil {
+				c.send("", typeConnectionError, errorPayload(err))
+				c.close(closeUnauthorized, err.Error())
+				return
+			}
+			c.start(ctx, msg)
+		case typeStop:
+			c.stop(msg.ID)
+			c.send(msg.ID, typeComplete, nil)
+		case typeConnectionTerminate:
+			return
+		case typeConnectionInit:
+			c.send("", typeConnectionError, errorPayload(errors.New("connection already initialised")))
+		default:
+			c.send(msg.ID, typeError, errorPayload(fmt.Errorf("unknown operation message of type: %s", msg.Type)))
+		}
+	}
+}
+
+// reauthenticate checks that the connection's token is still valid.
+func (c *connection) reauthenticate() error {
+	if c.token == "" {
+		return nil
+	}
+	_, err := c.handler.Authenticator.Authenticate(c.token)
+	return err
+}
+
+// start runs an operation and streams its results until it completes or the
+// client stops it.
+func (c *connection) start(ctx context.Context, msg message) {
+	if msg.ID == "" {
+		c.send("", typeConnectionError, errorPayload(errors.New("missing ID for start operation")))
+		return
+	}
+	var payload startPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		c.send(msg.ID, typeError, errorPayload(fmt.Errorf("invalid payload for type: %s", msg.Type)))
+		return
+	}
+
+	opCtx, cancel := context.WithCancel(ctx)
+	c.opsMu.Lock()
+	if _, ok := c.ops[msg.ID]; ok {
+		c.opsMu.Unlock()
+		cancel()
+		c.send(msg.ID, typeError, errorPayload(fmt.Errorf("operation %s is already running", msg.ID)))
+		return
+	}
+	c.ops[msg.ID] = cancel
+	c.opsMu.Unlock()
+
+	results, err := c.handler.Service.Subscribe(opCtx, payload.Query, payload.OperationName, payload.Variables)
+	if err != nil {
+		c.stop(msg.ID)
+		c.send(msg.ID, typeError, errorPayload(err))
+		c.send(msg.ID, typeComplete, nil)
+		return
+	}
+
+	go func() {
+		defer c.stop(msg.ID)
+		for {
+			select {
+			case <-opCtx.Done():
+				return
+			case result, ok := <-results:
+				if !ok {
+					c.send(msg.ID, typeComplete, nil)
+					return
+				}
+				data, err := json.Marshal(result)
+				if err != nil {
+					c.send(msg.ID, typeError, errorPayload(err))
+					continue
+				}
+				c.send(msg.ID, typeData, data)
+			}
+		}
+	}()
+}
+
+// stop cancels the operation with the given ID, if it is running.
+func (c *connection) stop(id string) {
+	c.opsMu.Lock()
+	cancel, ok := c.ops[id]
+	delete(c.ops, id)
+	c.opsMu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (c *connection) stopAll() {
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+	for id, cancel := range c.ops {
+		cancel()
+		delete(c.ops, id)
+	}
+}
+
+func (c *connection) keepAlive(ctx context.Context) {
+	c.send("", typeConnectionKeepAlive, nil)
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.send("", typeConnectionKeepAlive, nil)
+		}
+	}
+}
+
+// send writes a message to the client. Failed writes close the connection,
+// which ends the read loop.
+func (c *connection) send(id, typ string, payload json.RawMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := c.ws.WriteJSON(message{ID: id, Type: typ, Payload: payload}); err != nil {
+		c.ws.Close()
+	}
+}
+
+// close tells the client why the connection is ending and closes it.
+func (c *connection) close(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeTimeout))
+	c.ws.Close()
+}
+
+func errorPayload(err error) json.RawMessage {
+	payload, _ := json.Marshal(struct {
+		Message string `json:"message"`
+	}{err.Error()})
+	return payload
+}
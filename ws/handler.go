@@ -0,0 +1,92 @@
+// Package ws serves GraphQL subscriptions over websockets using the graphql-ws
+// protocol of subscriptions-transport-ws, authenticating connections with the
+// token clients send in their connection_init payload.
+package ws
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leggettc18/hackernews-clone-api/auth"
+)
+
+const protocol = "graphql-ws"
+
+const (
+	// initTimeout is how long clients have to send connection_init.
+	initTimeout = 10 * time.Second
+	// keepAliveInterval is how often keep-alive messages are sent.
+	keepAliveInterval = 15 * time.Second
+	// writeTimeout bounds writing a single message.
+	writeTimeout = 5 * time.Second
+	// readLimit is the largest message accepted from clients.
+	readLimit = 64 << 10
+)
+
+// Service runs GraphQL subscriptions. *graphql.Schema implements it.
+type Service interface {
+	Subscribe(ctx context.Context, document string, operationName string, variables map[string]interface{}) (<-chan interface{}, error)
+}
+
+// Handler upgrades graphql-ws requests to websocket connections and hands
+// every other request to Fallback.
+type Handler struct {
+	Service       Service
+	Authenticator auth.Authenticator
+	Fallback      http.Handler
+
+	upgrader websocket.Upgrader
+}
+
+// NewHandler returns a handler running subscriptions on service and
+// authenticating connections with authenticator.
+func NewHandler(service Service, authenticator auth.Authenticator, fallback http.Handler) *Handler {
+	return &Handler{
+		Service:       service,
+		Authenticator: authenticator,
+		Fallback:      fallback,
+		upgrader: websocket.Upgrader{
+			// Connections are authenticated with a token in connection_init
+			// rather than cookies, so other origins can't ride on them.
+			CheckOrigin:  func(r *http.Request) bool { return true },
+			Subprotocols: []string{protocol},
+		},
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) || !requestsProtocol(r) {
+		h.Fallback.ServeHTTP(w, r)
+		return
+	}
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error.
+		return
+	}
+	if conn.Subprotocol() != protocol {
+		conn.Close()
+		return
+	}
+
+	// The request context ends with this handler, so the connection gets its
+	// own, keeping what the middleware learned about the client.
+	ctx := auth.WithClientIP(context.Background(), auth.ClientIP(r.Context()))
+	var token string
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		ctx = auth.WithPrincipal(ctx, principal)
+		token = auth.BearerToken(r)
+	}
+	go newConnection(h, conn, token).serve(ctx)
+}
+
+func requestsProtocol(r *http.Request) bool {
+	for _, subprotocol := range websocket.Subprotocols(r) {
+		if subprotocol == protocol {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,357 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leggettc18/hackernews-clone-api/auth"
+)
+
+const testTimeout = 2 * time.Second
+
+// fakeAuthenticator accepts the tokens it holds principals for.
+type fakeAuthenticator struct {
+	mu     sync.Mutex
+	tokens map[string]*auth.Principal
+}
+
+func (a *fakeAuthenticator) Authenticate(token string) (*auth.Principal, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	principal, ok := a.tokens[token]
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+	return principal, nil
+}
+
+func (a *fakeAuthenticator) revoke(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.tokens, token)
+}
+
+// operation is a subscription started on fakeService.
+type operation struct {
+	ctx     context.Context
+	query   string
+	results chan interface{}
+}
+
+// fakeService runs every query as a subscription whose results the test
+// sends, except "fail", which is rejected.
+type fakeService struct {
+	started chan *operation
+}
+
+func (s *fakeService) Subscribe(ctx context.Context, query, operationName string, variables map[string]interface{}) (<-chan interface{}, error) {
+	if query == "fail" {
+		return nil, errors.New("invalid query")
+	}
+	op := &operation{ctx: ctx, query: query, results: make(chan interface{})}
+	s.started <- op
+	return op.results, nil
+}
+
+type testServer struct {
+	*httptest.Server
+	auth    *fakeAuthenticator
+	service *fakeService
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	s := &testServer{
+		auth: &fakeAuthenticator{tokens: map[string]*auth.Principal{
+			"valid": {UserID: 1, TokenID: "valid", TokenExpiresAt: time.Now().Add(time.Hour)},
+			"short": {UserID: 2, TokenID: "short", TokenExpiresAt: time.Now().Add(200 * time.Millisecond)},
+		}},
+		service: &fakeService{started: make(chan *operation, 10)},
+	}
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fallback"))
+	})
+	s.Server = httptest.NewServer(auth.Middleware(s.auth, false, NewHandler(s.service, s.auth, fallback)))
+	t.Cleanup(s.Close)
+	return s
+}
+
+type client struct {
+	t  *testing.T
+	ws *websocket.Conn
+}
+
+func (s *testServer) dial(t *testing.T, header http.Header) *client {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: []string{protocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(s.URL, "http"), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, ws: conn}
+}
+
+func (c *client) send(id, typ string, payload interface{}) {
+	c.t.Helper()
+	msg := map[string]interface{}{"id": id, "type": typ}
+	if payload != nil {
+		msg["payload"] = payload
+	}
+	if err := c.ws.WriteJSON(msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read returns the next message other than a keep-alive.
+func (c *client) read() message {
+	c.t.Helper()
+	for {
+		c.ws.SetReadDeadline(time.Now().Add(testTimeout))
+		var msg message
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			c.t.Fatalf("reading a message: %v", err)
+		}
+		if msg.Type != typeConnectionKeepAlive {
+			return msg
+		}
+	}
+}
+
+func (c *client) expect(id, typ string) message {
+	c.t.Helper()
+	msg := c.read()
+	if msg.ID != id || msg.Type != typ {
+		c.t.Fatalf("got %s message %q with payload %s, want %s message %q", msg.Type, msg.ID, msg.Payload, typ, id)
+	}
+	return msg
+}
+
+// expectClose reads until the server closes the connection and checks the
+// close code.
+func (c *client) expectClose(code int) {
+	c.t.Helper()
+	for {
+		c.ws.SetReadDeadline(time.Now().Add(testTimeout))
+		var msg message
+		err := c.ws.ReadJSON(&msg)
+		if err == nil {
+			continue
+		}
+		if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != code {
+			c.t.Fatalf("got %v, want close code %d", err, code)
+		}
+		return
+	}
+}
+
+func (c *client) init(payload interface{}) {
+	c.t.Helper()
+	c.send("", typeConnectionInit, payload)
+	c.expect("", typeConnectionAck)
+}
+
+func (s *testServer) started(t *testing.T) *operation {
+	t.Helper()
+	select {
+	case op := <-s.service.started:
+		return op
+	case <-time.After(testTimeout):
+		t.Fatal("operation not started")
+		return nil
+	}
+}
+
+func TestConnectionInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  http.Header
+		payload interface{}
+		userID  uint
+	}{
+		{"no token", nil, nil, 0},
+		{"empty payload", nil, map[string]interface{}{}, 0},
+		{"authToken", nil, map[string]interface{}{"authToken": "valid"}, 1},
+		{"Authorization", nil, map[string]interface{}{"Authorization": "Bearer valid"}, 1},
+		{"upgrade request header", http.Header{"Authorization": {"Bearer valid"}}, nil, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+			c := s.dial(t, test.header)
+			c.init(test.payload)
+			c.send("1", typeStart, startPayload{Query: "subscription"})
+			op := s.started(t)
+			principal, ok := auth.FromContext(op.ctx)
+			switch {
+			case test.userID == 0 && ok:
+				t.Errorf("anonymous connection has principal %+v", principal)
+			case test.userID != 0 && (!ok || principal.UserID != test.userID):
+				t.Errorf("got principal %+v, want user %d", principal, test.userID)
+			}
+		})
+	}
+}
+
+func TestConnectionInitRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		payload interface{}
+		code    int
+	}{
+		{"invalid token", typeConnectionInit, map[string]interface{}{"authToken": "invalid"}, closeUnauthorized},
+		{"invalid payload", typeConnectionInit, "token", closeBadRequest},
+		{"start before init", typeStart, startPayload{Query: "subscription"}, closeBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestServer(t).dial(t, nil)
+			c.send("", test.typ, test.payload)
+			c.expect("", typeConnectionError)
+			c.expectClose(test.code)
+		})
+	}
+}
+
+func TestTokenExpiryClosesConnection(t *testing.T) {
+	c := newTestServer(t).dial(t, nil)
+	c.init(map[string]interface{}{"authToken": "short"})
+	msg := c.expect("", typeConnectionError)
+	if !strings.Contains(string(msg.Payload), errTokenExpired.Error()) {
+		t.Errorf("got payload %s, want the token expiry", msg.Payload)
+	}
+	c.expectClose(closeUnauthorized)
+}
+
+func TestRevokedTokenClosesConnectionOnStart(t *testing.T) {
+	for _, header := range []http.Header{nil, {"Authorization": {"Bearer valid"}}} {
+		s := newTestServer(t)
+		c := s.dial(t, header)
+		var payload interface{}
+		if header == nil {
+			payload = map[string]interface{}{"authToken": "valid"}
+		}
+		c.init(payload)
+		c.send("1", typeStart, startPayload{Query: "subscription"})
+		s.started(t)
+
+		s.auth.revoke("valid")
+		c.send("2", typeStart, startPayload{Query: "subscription"})
+		c.expect("", typeConnectionError)
+		c.expectClose(closeUnauthorized)
+	}
+}
+
+func TestStartStopComplete(t *testing.T) {
+	s := newTestServer(t)
+	c := s.dial(t, nil)
+	c.init(nil)
+
+	c.send("1", typeStart, startPayload{Query: "first"})
+	first := s.started(t)
+	c.send("2", typeStart, startPayload{Query: "second"})
+	second := s.started(t)
+	if first.query != "first" || second.query != "second" {
+		t.Fatalf("started %q and %q", first.query, second.query)
+	}
+
+	first.results <- map[string]string{"n": "1"}
+	msg := c.expect("1", typeData)
+	if string(msg.Payload) != `{"n":"1"}` {
+		t.Errorf("got data %s", msg.Payload)
+	}
+
+	// The client stops the first operation.
+	c.send("1", typeStop, nil)
+	c.expect("1", typeComplete)
+	select {
+	case <-first.ctx.Done():
+	case <-time.After(testTimeout):
+		t.Error("stopped operation's context not cancelled")
+	}
+
+	// The second completes on its own.
+	second.results <- "last"
+	c.expect("2", typeData)
+	close(second.results)
+	c.expect("2", typeComplete)
+
+	// IDs can be reused once their operation is over.
+	c.send("1", typeStart, startPayload{Query: "again"})
+	if op := s.started(t); op.query != "again" {
+		t.Errorf("started %q, want again", op.query)
+	}
+
+	c.send("", typeConnectionTerminate, nil)
+	c.expectClose(websocket.CloseAbnormalClosure)
+}
+
+func TestStartErrors(t *testing.T) {
+	s := newTestServer(t)
+	c := s.dial(t, nil)
+	c.init(nil)
+
+	c.send("1", typeStart, startPayload{Query: "fail"})
+	msg := c.expect("1", typeError)
+	if !strings.Contains(string(msg.Payload), "invalid query") {
+		t.Errorf("got payload %s, want the subscription error", msg.Payload)
+	}
+	c.expect("1", typeComplete)
+
+	c.send("2", typeStart, "not an object")
+	c.expect("2", typeError)
+
+	c.send("", typeStart, startPayload{Query: "subscription"})
+	c.expect("", typeConnectionError)
+
+	c.send("3", typeStart, startPayload{Query: "subscription"})
+	s.started(t)
+	c.send("3", typeStart, startPayload{Query: "subscription"})
+	c.expect("3", typeError)
+
+	c.send("", typeConnectionInit, nil)
+	c.expect("", typeConnectionError)
+}
+
+func TestUnknownMessageType(t *testing.T) {
+	c := newTestServer(t).dial(t, nil)
+	c.init(nil)
+	c.send("7", "subscribe", nil)
+	msg := c.expect("7", typeError)
+	var payload struct{ Message string }
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Message != "unknown operation message of type: subscribe" {
+		t.Errorf("got %q", payload.Message)
+	}
+	// The connection stays usable.
+	c.send("8", "", nil)
+	c.expect("8", typeError)
+}
+
+func TestFallback(t *testing.T) {
+	s := newTestServer(t)
+	resp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "fallback" {
+		t.Errorf("got %q, want the fallback handler", body)
+	}
+}